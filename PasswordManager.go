@@ -2,182 +2,653 @@
 // main.go
 // Author: Zarak Khan
 //
-// A simple command‑line password manager. Stores credentials in a map
-// keyed by website, each value being a slice of Entry structs. The program
-// supports:
-//   • Optional initialization from a whitespace‑separated file (site user pass)
-//   • Listing (L) – display all stored credentials
-//   • Adding  (A) – add a new (site, user, pass) triple, rejecting duplicates
+// A simple command‑line password manager. Credentials live in a
+// vault.Store keyed by website, each value being a slice of Entry structs.
+// The program supports:
+//   • Optional initialization from an encrypted vault file (see vault/file.go),
+//     or a legacy whitespace‑separated file (site user pass) to import
+//   • Listing (L) – display all stored credentials sorted by site and user,
+//     passwords masked unless given as "L --show"; --sort and --format
+//     select other orders and table, JSON or CSV output; with a breach
+//     list configured, passwords found in it are flagged
+//   • Reveal   (V) – show the password for one site and user
+//   • Copy     (C) – put a password on the clipboard, cleared after a timeout
+//   • Details  (D) – show an entry's URL, notes, tags, timestamps and fields
+//   • TOTP     (T) – show the current two-factor code for an entry
+//   • Find     (F) – search site, user, tags and notes by substring, glob or
+//     fuzzy match, optionally restricted to one tag
+//   • Adding  (A) – add a new (site, user, pass) triple, rejecting duplicates;
+//     give only site and user to be prompted for the password without echo
+//     (or to have a random one generated)
+//   • Updating (U) – rename a user and/or change their password in place
+//   • Metadata (M) – edit an entry's URL, notes, tags, custom fields and
+//     two-factor secret
+//   • History  (H) – list a user's previous passwords, newest first
+//   • Rollback (B) – restore one of those previous passwords
 //   • Removing (R) – delete a whole site (single user) or a specific user
-//   • Exit     (X)
+//   • Log      (G) – list the passwords revealed during this session
+//   • Password (P) – change the master password, re-encrypting the vault
+//     and optionally raising an outdated key derivation cost
+//   • Lock     (K) – wipe the decrypted entries until the master password
+//     is entered again; the menu also locks itself after sitting idle
+//   • Use      (use) – save the current vault and switch to another named
+//     vault, each with its own file and master password (see vaults.go)
+//   • Move     (move) – move a site or user into another named vault
+//   • Saving   (S) – re‑encrypt and atomically write the map to the vault file
+//   • Exit     (X) – save any unsaved changes, then exit
+//
+// The same operations are available non‑interactively as subcommands
+// (list, add, update, history, rollback, remove, get, show, totp, search, copy,
+// import, export, audit, breach-check, passwd, generate, serve, agent,
+// agent-lock, vaults, move); see commands.go. Read-only subcommands use a
+// running agent (see agent.go) instead of asking for the master password.
 //
 // Prompts, error messages, and output format match the assignment’s sample
-// The data structure is fixed as map[string]EntrySlice.
 // ----------------------------------------------------------------------
 
 package main
 
 import (
     "bufio"
+    "errors"
     "fmt"
+    "io"
     "os"
+    "strconv"
     "strings"
+
+    "github.com/ZarakL/Go-Projects/passgen"
+    "github.com/ZarakL/Go-Projects/vault"
 )
 
-// Entry represents one credential record.
-type Entry struct {
-    site, user, password string
-}
+// store holds the credentials for this run (see package vault).
+var store = vault.New()
 
-// EntrySlice is a helper alias for slices of Entry.
-type EntrySlice []Entry
+// vaultPath and masterPassword identify the vault written back on exit.
+// vaultPath is empty when the map was started empty (N/A).
+var vaultPath, masterPassword string
 
-// passwordMap maps a website → its stored credentials.
-var passwordMap map[string]EntrySlice
+// dirty records whether store has changes not yet written to disk.
+var dirty bool
 
-// addEntry inserts a credential if (site,user) is not already present.
-// Returns true on success, false if duplicate.
-func addEntry(site, user, pass string, reportDup bool) bool {
-    slice := passwordMap[site]
-    for _, e := range slice {
-        if e.user == user {
-            if reportDup {
-                fmt.Println("**Error: Attempting to add a duplicate entry. Try again.")
-            }
-            return false
+// menuErrors keeps the A and R menu commands' original wording for store
+// errors.
+var menuErrors = map[error]string{
+    vault.ErrDuplicate:       "**Error: Attempting to add a duplicate entry. Try again.",
+    vault.ErrSiteNotFound:    "**Error: Attempt to remove a website that does not exist in the map. Try again.",
+    vault.ErrAmbiguousRemove: "**Error: Attempt to remove multiple users. Try again.",
+    vault.ErrUserNotFound:    "**Error: Attempt to remove a username that does not exist in the map. Try again.",
+}
+
+// menuErrorText formats an error from the A or R menu commands.
+func menuErrorText(err error) string {
+    for target, msg := range menuErrors {
+        if errors.Is(err, target) {
+            return msg
         }
     }
-    passwordMap[site] = append(slice, Entry{site: site, user: user, password: pass})
-    return true
+    return errorText(err)
+}
+
+// errorText formats err for display with the usual "**Error" prefix.
+func errorText(err error) string {
+    msg := err.Error()
+    if !strings.HasPrefix(msg, "**Error") {
+        msg = "**Error: " + msg
+    }
+    return msg
 }
 
-// listAll prints the entire password map.
-func listAll() {
-    for site, slice := range passwordMap {
-        fmt.Printf("Website: %s\n", site)
-        for _, e := range slice {
-            fmt.Printf("\t %s \t %s\n", e.user, e.password)
+// listAll prints the entire password map, sorted and formatted as o
+// selects (see listing.go).
+func listAll(o listOptions) error {
+    return printListing(store.List(), o)
+}
+
+// printEntries prints entries grouped under a "Website:" heading per site,
+// masking the passwords unless o.show is set and flagging any found in the
+// breach list.
+func printEntries(entries vault.EntrySlice, o listOptions) {
+    var site string
+    for i, e := range entries {
+        if e.Site != site {
+            if i > 0 {
+                fmt.Println()
+            }
+            site = e.Site
+            fmt.Printf("Website: %s\n", site)
         }
+        pw := passwordMask
+        if o.show {
+            pw = e.Password
+        }
+        if n, ok := o.breaches[entryKey{e.Site, e.User}]; ok {
+            fmt.Printf("\t %s \t %s \t **Breached: seen %d times\n", e.User, pw, n)
+            continue
+        }
+        fmt.Printf("\t %s \t %s\n", e.User, pw)
+    }
+    if site != "" {
         fmt.Println()
     }
 }
 
-// removeEntry handles R‑command logic according to spec.
-func removeEntry(line string) {
+// removeEntry handles R‑command logic according to spec: a site alone
+// removes the whole site, a site and username removes just that user.
+// Anything after the username is ignored.
+func removeEntry(line string) error {
     fields := strings.Fields(line)
     if len(fields) == 0 {
-        return
+        return nil
     }
+    fields = append(fields, "")
+    if err := store.Remove(fields[0], fields[1]); err != nil {
+        return err
+    }
+    dirty = true
+    return nil
+}
 
-    site := fields[0]
-    slice, ok := passwordMap[site]
-    if !ok {
-        fmt.Println("**Error: Attempt to remove a website that does not exist in the map. Try again.")
-        return
+// readFile initializes the map from a given file path, reporting any
+// lines that were skipped.
+func readFile(path string) error {
+    fmt.Println("Initializing map using file...")
+    f, err := os.Open(path)
+    if err != nil {
+        return err
     }
+    defer f.Close()
 
-    // Only website provided
-    if len(fields) == 1 {
-        if len(slice) > 1 {
-            fmt.Println("**Error: Attempt to remove multiple users. Try again.")
-            return
+    rep, err := store.ImportLegacy(f)
+    if rep.Added > 0 {
+        dirty = true
+    }
+    printImportReport(os.Stdout, rep)
+    if err != nil {
+        return err
+    }
+    fmt.Println("Done reading in file.")
+    return nil
+}
+
+// printImportReport lists skipped, duplicate, overwritten and renamed
+// lines from an import.
+func printImportReport(w io.Writer, rep vault.ImportReport) {
+    for _, l := range rep.Skipped {
+        if l.Text == "" {
+            fmt.Fprintf(w, "**Warning: Skipped line %d (%s)\n", l.Line, l.Reason)
+            continue
         }
-        delete(passwordMap, site)
-        return
+        fmt.Fprintf(w, "**Warning: Skipped line %d (%s): %s\n", l.Line, l.Reason, l.Text)
+    }
+    for _, l := range rep.Duplicates {
+        fmt.Fprintf(w, "**Warning: Duplicate entry on line %d ignored: %s\n", l.Line, l.Text)
+    }
+    for _, l := range rep.Overwritten {
+        fmt.Fprintf(w, "Line %d %s.\n", l.Line, l.Reason)
     }
+    for _, l := range rep.Renamed {
+        fmt.Fprintf(w, "Line %d duplicated an existing entry and was %s.\n", l.Line, l.Reason)
+    }
+    fmt.Fprintf(w, "Imported %d entries (%d skipped, %d duplicates", rep.Added, len(rep.Skipped), len(rep.Duplicates))
+    if len(rep.Overwritten) > 0 {
+        fmt.Fprintf(w, ", %d overwritten", len(rep.Overwritten))
+    }
+    if len(rep.Renamed) > 0 {
+        fmt.Fprintf(w, ", %d renamed", len(rep.Renamed))
+    }
+    fmt.Fprintln(w, ").")
+}
+
+// openFile loads path into store. Encrypted vaults are decrypted with the
+// master password; anything else is imported as a legacy plaintext file
+// and will be written back encrypted. A missing file starts a new vault.
+func openFile(path string) error {
+    data, err := os.ReadFile(path)
+    if os.IsNotExist(err) {
+        fmt.Println("Vault file not found; a new vault will be created on save.")
+        return nil
+    }
+    if err != nil {
+        return err
+    }
+    if !vault.IsVault(data) {
+        if err := readFile(path); err != nil {
+            return err
+        }
+        fmt.Println("Plaintext file imported; it will be replaced by an encrypted vault on save.")
+        dirty = true
+        return nil
+    }
+    s, err := vault.Unseal(data, masterPassword)
+    if err != nil {
+        return err
+    }
+    store = s
+    fmt.Println("Vault unlocked.")
+    return nil
+}
 
-    // Website + username provided
-    username := fields[1]
-    idx := -1
-    for i, e := range slice {
-        if e.user == username {
-            idx = i
-            break
+// save writes store to the vault file, first asking for a filename (and a
+// master password, unless one was chosen when locking) if the map was
+// started empty. Returns true on success.
+func save(reader *bufio.Reader) bool {
+    if vaultPath == "" {
+        fmt.Print("Enter a filename to save the vault to: ")
+        name, _ := reader.ReadString('\n')
+        name = strings.TrimSpace(name)
+        if name == "" {
+            fmt.Println("**Error: No filename given. Vault not saved.")
+            return false
         }
+        if masterPassword == "" {
+            pw, err := readNewPassword(os.Stdout, "Enter a master password for the vault: ")
+            if err != nil {
+                fmt.Println(errorText(err), "Vault not saved.")
+                return false
+            }
+            masterPassword = pw
+        }
+        vaultPath = name
     }
-    if idx == -1 {
-        fmt.Println("**Error: Attempt to remove a username that does not exist in the map. Try again.")
+    if err := store.Save(vaultPath, masterPassword); err != nil {
+        fmt.Println("**Error writing vault file:", err)
+        return false
+    }
+    dirty = false
+    fmt.Println("Vault saved.")
+    return true
+}
+
+// confirmDiscard warns about unsaved changes and reports whether the user
+// still wants to exit.
+func confirmDiscard(reader *bufio.Reader) bool {
+    fmt.Print("**Warning: You have unsaved changes that will be lost. Exit anyway? (Y/N): ")
+    ans, _ := reader.ReadString('\n')
+    return strings.ToUpper(strings.TrimSpace(ans)) == "Y"
+}
+
+// promptEntryPassword asks for the password of a new entry without echoing
+// it, generating a random one if the answer is left blank.
+func promptEntryPassword() (string, error) {
+    pw, err := readNewPassword(os.Stdout, "Enter the password (leave blank to generate one): ")
+    if err != nil || pw != "" {
+        return pw, err
+    }
+    pw, err = passgen.Password(passgen.DefaultOptions)
+    if err == nil {
+        fmt.Println("Generated password:", pw)
+    }
+    return pw, err
+}
+
+// updateEntry handles the U command: rename a user and/or change their
+// password, keeping the old password in the entry's history.
+func updateEntry(reader *bufio.Reader) {
+    fmt.Print("Enter the site and username (separated by spaces): ")
+    line, _ := reader.ReadString('\n')
+    parts := strings.Fields(line)
+    if len(parts) != 2 {
         return
     }
-    slice = append(slice[:idx], slice[idx+1:]...)
-    if len(slice) == 0 {
-        delete(passwordMap, site)
-    } else {
-        passwordMap[site] = slice
+    if _, err := store.Get(parts[0], parts[1]); err != nil {
+        fmt.Println(errorText(err))
+        return
     }
+    fmt.Print("Enter the new username (leave blank to keep it): ")
+    newUser, _ := reader.ReadString('\n')
+    newUser = strings.TrimSpace(newUser)
+    newPass, err := readNewPassword(os.Stdout, "Enter the new password (leave blank to keep it): ")
+    if err != nil {
+        fmt.Println(errorText(err))
+        return
+    }
+    if strings.ContainsAny(newUser+newPass, " \t") {
+        fmt.Println("**Error: User names and passwords may not contain spaces. Try again.")
+        return
+    }
+    if err := store.Update(parts[0], parts[1], newUser, newPass); err != nil {
+        fmt.Println(errorText(err))
+        return
+    }
+    dirty = true
 }
 
-// readFile initializes the map from a given file path.
-func readFile(path string) {
-    fmt.Println("Initializing map using file...")
-    f, err := os.Open(path)
+// rollbackEntry handles the B command: restore one of an entry's previous
+// passwords.
+func rollbackEntry(reader *bufio.Reader) {
+    fmt.Print("Enter the site and username (separated by spaces): ")
+    line, _ := reader.ReadString('\n')
+    parts := strings.Fields(line)
+    if len(parts) != 2 {
+        return
+    }
+    if err := printHistory(parts[0], parts[1], false); err != nil {
+        fmt.Println(errorText(err))
+        return
+    }
+    fmt.Print("Enter the version to restore (1 = previous password): ")
+    numLine, _ := reader.ReadString('\n')
+    n, err := strconv.Atoi(strings.TrimSpace(numLine))
     if err != nil {
-        fmt.Println("**Error opening file. Exiting program...")
-        os.Exit(1)
+        fmt.Println("**Error: Invalid version number. Try again.")
+        return
     }
-    defer f.Close()
+    if err := store.Rollback(parts[0], parts[1], n); err != nil {
+        fmt.Println(errorText(err))
+        return
+    }
+    dirty = true
+}
 
-    scanner := bufio.NewScanner(f)
-    for scanner.Scan() {
-        parts := strings.Fields(scanner.Text())
-        if len(parts) == 3 {
-            addEntry(parts[0], parts[1], parts[2], false)
+// editMeta handles the M command: change the URL, notes, tags or a custom
+// field of an entry. Blank answers keep the current value.
+func editMeta(reader *bufio.Reader) {
+    fmt.Print("Enter the site and username (separated by spaces): ")
+    line, _ := reader.ReadString('\n')
+    parts := strings.Fields(line)
+    if len(parts) != 2 {
+        return
+    }
+    e, err := store.Get(parts[0], parts[1])
+    if err != nil {
+        fmt.Println(errorText(err))
+        return
+    }
+    ask := func(prompt string) string {
+        fmt.Print(prompt)
+        ans, _ := reader.ReadString('\n')
+        return strings.TrimSpace(ans)
+    }
+
+    if url := ask("Enter the URL (leave blank to keep it): "); url != "" {
+        e.URL = url
+    }
+    if notes := ask("Enter the notes (leave blank to keep them): "); notes != "" {
+        e.Notes = notes
+    }
+    switch tags := ask("Enter the tags separated by commas (leave blank to keep them, - to clear): "); tags {
+    case "":
+    case "-":
+        e.Tags = nil
+    default:
+        e.Tags = parseTags(tags)
+    }
+    if field := ask("Enter a custom field as name=value (leave blank to skip): "); field != "" {
+        secret := strings.ToUpper(ask("Is this field secret? (Y/N): ")) == "Y"
+        f, err := parseField(field, secret)
+        if err != nil {
+            fmt.Println(err)
+            return
         }
+        e.SetField(f)
     }
-    fmt.Println("Done reading in file.")
+    switch secret := ask("Enter a TOTP secret or otpauth:// URI (leave blank to keep it, - to clear): "); secret {
+    case "":
+    case "-":
+        e.TOTP = ""
+    default:
+        uri, err := parseTOTP(secret)
+        if err != nil {
+            fmt.Println(errorText(err))
+            return
+        }
+        e.TOTP = uri
+    }
+    if err := store.SetMeta(parts[0], parts[1], e.Meta); err != nil {
+        fmt.Println(errorText(err))
+        return
+    }
+    dirty = true
 }
 
 // printMenu shows the main command menu.
 func printMenu() {
     fmt.Println()
     fmt.Println("Select a menu option: ")
-    fmt.Println("\t L to list the contents of the map")
+    fmt.Println("\t L to list the contents of the map (L --show to reveal passwords;")
+    fmt.Println("\t   --sort site|user|modified and --format text|table|json|csv also work)")
+    fmt.Println("\t V to reveal the password for a website and user")
+    fmt.Println("\t C to copy a password to the clipboard")
+    fmt.Println("\t D to show an entry's details (D --show to reveal secrets)")
+    fmt.Println("\t T to show an entry's current two-factor code")
+    fmt.Println("\t F to find entries (F --glob, F --fuzzy, F --tag tag)")
     fmt.Println("\t A to add a new entry to the map")
+    fmt.Println("\t U to update a user's name and/or password")
+    fmt.Println("\t M to edit an entry's URL, notes, tags, custom fields and TOTP secret")
+    fmt.Println("\t H to view a user's password history (H --show to reveal)")
+    fmt.Println("\t B to roll back to a previous password")
     fmt.Println("\t R to remove a website and/or user")
+    fmt.Println("\t G to show which passwords were revealed this session")
+    fmt.Println("\t P to change the master password")
+    fmt.Println("\t K to lock the session until the master password is entered")
+    fmt.Println("\t use [name] to switch to a named vault (use alone lists them)")
+    fmt.Println("\t move to move an entry to another named vault")
+    fmt.Println("\t S to save the map to the vault file")
     fmt.Println(" or X to exit the program.")
     fmt.Print("Your choice --> ")
 }
 
 // ----------------------------------------------------------------------
-// main starts the interactive loop.
+// main runs a subcommand (see commands.go), or the interactive shell when
+// none is given.
 func main() {
-    passwordMap = make(map[string]EntrySlice)
-    reader := bufio.NewReader(os.Stdin)
-
-    // Optional file initialization.
-    fmt.Print("Enter a filename if you would like to initialize the map using a file\n")
-    fmt.Print("(or enter N/A if the map should start as empty): ")
-    firstLine, _ := reader.ReadString('\n')
-    firstLine = strings.TrimSpace(firstLine)
-    // ensure next output starts on a new line (matches sample I/O)
-    fmt.Println()
-    if strings.ToUpper(firstLine) != "N/A" && firstLine != "" {
-        readFile(firstLine)
+    os.Exit(run(os.Args[1:]))
+}
+
+// shell starts the interactive loop. Unless a vault was named on the
+// command line, the user is first asked for a file to initialize from.
+// An error is returned only if that file cannot be loaded.
+func shell(reader *bufio.Reader) error {
+    if vaultPath == "" {
+        // Optional file initialization.
+        fmt.Print("Enter a filename if you would like to initialize the map using a file\n")
+        fmt.Print("(or enter N/A if the map should start as empty): ")
+        firstLine, _ := reader.ReadString('\n')
+        firstLine = strings.TrimSpace(firstLine)
+        // ensure next output starts on a new line (matches sample I/O)
+        fmt.Println()
+        if strings.ToUpper(firstLine) != "N/A" && firstLine != "" {
+            vaultPath = firstLine
+        }
+    }
+    if vaultPath != "" {
+        if masterPassword == "" {
+            // A vault that is about to be created, or a plaintext file that
+            // will be encrypted, gets a new password that must be confirmed.
+            read := readNewPassword
+            if data, err := os.ReadFile(vaultPath); err == nil && vault.IsVault(data) {
+                read = readPassword
+            }
+            pw, err := read(os.Stdout, "Enter the master password: ")
+            if err != nil {
+                fmt.Println(errorText(err))
+                fmt.Println("Exiting program...")
+                return err
+            }
+            masterPassword = pw
+        }
+        if err := openFile(vaultPath); err != nil {
+            fmt.Println(errorText(err))
+            fmt.Println("Exiting program...")
+            return err
+        }
     }
 
-    // Command loop.
+    // Command loop. The session lock is held except while waiting at the
+    // menu prompt, where the idle timer may lock it.
+    var sess session
+    sess.mu.Lock()
     for {
+        if sess.locked() {
+            if err := sess.unlock(); err != nil {
+                sess.exitLocked()
+                return nil
+            }
+        }
         printMenu()
-        cmdLine, _ := reader.ReadString('\n')
-        cmd := strings.TrimSpace(cmdLine)
+        sess.wait()
+        cmdLine, err := reader.ReadString('\n')
+        sess.resume()
+        if err != nil && cmdLine == "" && sess.locked() {
+            sess.exitLocked()
+            return nil
+        }
+        if err != nil && cmdLine == "" {
+            // Input ended without an X: there is nobody left to ask, so
+            // save what we can and stop.
+            fmt.Println()
+            if dirty && vaultPath != "" {
+                save(reader)
+            }
+            clip.Flush()
+            fmt.Println("Exiting program.")
+            return nil
+        }
+        if sess.locked() {
+            // Whatever was typed at the lock message is not a command.
+            continue
+        }
+        fields := strings.Fields(cmdLine)
+        cmd := ""
+        if len(fields) > 0 {
+            cmd = fields[0]
+        }
 
         switch cmd {
         case "L":
-            listAll()
+            o, _, err := parseListFlags(fields[1:])
+            if err == nil {
+                err = listAll(o)
+            }
+            if err != nil {
+                fmt.Println("**Error: Use L [--show] [--sort site|user|modified] [--format text|table|json|csv]. Try again.")
+            }
+        case "V":
+            fmt.Print("Enter the site and username (separated by spaces, username optional): ")
+            line, _ := reader.ReadString('\n')
+            parts := append(strings.Fields(line), "", "")
+            if parts[0] == "" {
+                break
+            }
+            if err := revealEntry(parts[0], parts[1]); err != nil {
+                fmt.Println(errorText(err))
+            }
+        case "T":
+            fmt.Print("Enter the site and username (separated by spaces, username optional): ")
+            line, _ := reader.ReadString('\n')
+            parts := append(strings.Fields(line), "", "")
+            if parts[0] == "" {
+                break
+            }
+            if err := printTOTP(parts[0], parts[1]); err != nil {
+                fmt.Println(errorText(err))
+            }
+        case "G":
+            printRevealLog()
+        case "C":
+            fmt.Print("Enter the site and username (separated by spaces, username optional): ")
+            line, _ := reader.ReadString('\n')
+            parts := append(strings.Fields(line), "", "")
+            if parts[0] == "" {
+                break
+            }
+            if err := copyEntry(parts[0], parts[1]); err != nil {
+                fmt.Println(errorText(err))
+            }
         case "A":
             fmt.Print("Enter the site, username, and password (separated by spaces): ")
             entryLine, _ := reader.ReadString('\n')
             parts := strings.Fields(entryLine)
+            if len(parts) == 2 {
+                pw, err := promptEntryPassword()
+                if err != nil {
+                    fmt.Println(errorText(err))
+                    break
+                }
+                parts = append(parts, pw)
+            }
             if len(parts) == 3 {
-                addEntry(parts[0], parts[1], parts[2], true)
+                if err := store.Add(parts[0], parts[1], parts[2]); err != nil {
+                    fmt.Println(menuErrorText(err))
+                } else {
+                    dirty = true
+                }
+            }
+        case "U":
+            updateEntry(reader)
+        case "H":
+            fmt.Print("Enter the site and username (separated by spaces): ")
+            line, _ := reader.ReadString('\n')
+            parts := strings.Fields(line)
+            if len(parts) != 2 {
+                break
+            }
+            show := len(fields) > 1 && (fields[1] == "--show" || fields[1] == "-show")
+            if err := printHistory(parts[0], parts[1], show); err != nil {
+                fmt.Println(errorText(err))
+            }
+        case "B":
+            rollbackEntry(reader)
+        case "D":
+            fmt.Print("Enter the site and username (separated by spaces, username optional): ")
+            line, _ := reader.ReadString('\n')
+            parts := append(strings.Fields(line), "", "")
+            if parts[0] == "" {
+                break
+            }
+            show := len(fields) > 1 && (fields[1] == "--show" || fields[1] == "-show")
+            if err := printDetails(parts[0], parts[1], show); err != nil {
+                fmt.Println(errorText(err))
             }
+        case "M":
+            editMeta(reader)
+        case "F":
+            q, err := parseSearch(fields[1:])
+            if err != nil {
+                fmt.Println("**Error: Use F [--glob | --fuzzy] [--tag tag]. Try again.")
+                break
+            }
+            fmt.Print("Enter the text to search for (blank for all): ")
+            text, _ := reader.ReadString('\n')
+            q.Text = strings.TrimSpace(text)
+            printResults(store.Search(q))
         case "R":
             fmt.Print("Enter the site and username (separated by spaces, username optional): ")
             remLine, _ := reader.ReadString('\n')
-            removeEntry(remLine)
+            if err := removeEntry(remLine); err != nil {
+                fmt.Println(menuErrorText(err))
+            }
+        case "P":
+            changeMasterPassword(reader)
+        case "K":
+            lockSession(&sess)
+        case "use":
+            if len(fields) > 2 {
+                fmt.Println("**Error: Use use [name]. Try again.")
+                break
+            }
+            if err := useVault(reader, strings.Join(fields[1:], "")); err != nil {
+                fmt.Println(errorText(err))
+            }
+        case "move":
+            if err := moveToVault(reader); err != nil {
+                fmt.Println(errorText(err))
+            }
+        case "S":
+            save(reader)
         case "X":
+            // Save automatically when there is a vault to write to; only
+            // an unnamed map or a failed save needs confirmation.
+            if dirty && (vaultPath == "" || !save(reader)) && !confirmDiscard(reader) {
+                continue
+            }
+            // Do not leave a password behind on the clipboard.
+            if err := clip.Flush(); err != nil {
+                fmt.Println(errorText(err))
+            }
             fmt.Println("Exiting program.")
-            return
+            return nil
         default:
             fmt.Println("**Error, unknown command. Try again.")
         }
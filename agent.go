@@ -0,0 +1,419 @@
+// agent.go
+// Background agent that keeps an unlocked vault for short-lived commands
+// Zarak Khan
+//
+// Like ssh-agent, "pm agent" asks for the master password once, then
+// detaches and serves the decrypted vault over the HTTP/JSON API (see
+// package api) on a Unix socket in a directory only this user can write
+// to. Only processes of the same user may connect, which is checked
+// with the socket's peer credentials rather than a token, and clients
+// check the same of the agent before trusting it. The agent wipes the
+// store and exits when its TTL runs out or "pm agent-lock" is run.
+// Read-only commands use the agent when $PM_AGENT_SOCK is set and fall
+// back to opening the vault themselves. Other commands write the vault
+// file directly; the agent reloads it when it changes and never saves
+// over a newer file. Only Linux can check peer credentials, so
+// elsewhere the agent is not available.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ZarakL/Go-Projects/api"
+	"github.com/ZarakL/Go-Projects/vault"
+)
+
+// agentVaultHeader carries the absolute vault path a client expects, so
+// an agent holding a different vault refuses the request.
+const agentVaultHeader = "Pm-Vault"
+
+var (
+	agentUnsupportedErr = usageError("The agent is not supported on this platform.")
+	agentRunningErr     = errors.New("**Error: An agent is already listening on that socket.")
+	agentStartErr       = errors.New("**Error: The agent did not start.")
+	noAgentErr          = errors.New("**Error: No agent is running.")
+	foreignAgentErr     = errors.New("**Error: The agent socket belongs to another user.")
+	agentLockedErr      = errors.New("agent is locked")
+)
+
+// agentDirPrefix names the private directories made for agent sockets.
+const agentDirPrefix = "pm-agent-"
+
+// agentSocketPath returns the socket clients should use: $PM_AGENT_SOCK,
+// else the default in $XDG_RUNTIME_DIR, else "".
+func agentSocketPath() string {
+	if p := os.Getenv("PM_AGENT_SOCK"); p != "" {
+		return p
+	}
+	return defaultAgentSocket()
+}
+
+// defaultAgentSocket returns a socket path in $XDG_RUNTIME_DIR, which
+// only this user can write to, or "" if it is not set. Without it the
+// agent makes a private directory with a random name, as ssh-agent does,
+// so another user cannot take the path first.
+func defaultAgentSocket() string {
+	if d := os.Getenv("XDG_RUNTIME_DIR"); d != "" {
+		return filepath.Join(d, "pm-agent.sock")
+	}
+	return ""
+}
+
+// privateAgentSocket makes a new directory readable only by this user
+// and returns a socket path inside it.
+func privateAgentSocket() (string, error) {
+	dir, err := os.MkdirTemp("", agentDirPrefix)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "agent.sock"), nil
+}
+
+// removeAgentSocket removes socket and, if it was made by
+// privateAgentSocket, its directory.
+func removeAgentSocket(socket string) {
+	os.Remove(socket)
+	dir := filepath.Dir(socket)
+	if filepath.Dir(dir) == filepath.Clean(os.TempDir()) && strings.HasPrefix(filepath.Base(dir), agentDirPrefix) {
+		os.Remove(dir)
+	}
+}
+
+// dialAgent connects to the agent on socket after checking that both the
+// socket file and the process listening on it belong to this user, so
+// another user cannot stand in for the agent.
+func dialAgent(ctx context.Context, socket string) (net.Conn, error) {
+	fi, err := os.Lstat(socket)
+	if err != nil {
+		return nil, err
+	}
+	if uid, ok := fileUID(fi); fi.Mode()&os.ModeSocket == 0 || !ok || uid != os.Getuid() {
+		return nil, foreignAgentErr
+	}
+	var d net.Dialer
+	c, err := d.DialContext(ctx, "unix", socket)
+	if err != nil {
+		return nil, err
+	}
+	if uid, err := peerUID(c.(*net.UnixConn)); err != nil || uid != os.Getuid() {
+		c.Close()
+		return nil, foreignAgentErr
+	}
+	return c, nil
+}
+
+// pingAgent checks that an agent of this user answers on socket.
+func pingAgent(socket string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	c, err := dialAgent(ctx, socket)
+	if err != nil {
+		return err
+	}
+	return c.Close()
+}
+
+// agentMissing reports whether err means that no agent is listening.
+func agentMissing(err error) bool {
+	var opErr *net.OpError
+	return errors.Is(err, fs.ErrNotExist) || errors.As(err, &opErr)
+}
+
+// peerCheckListener accepts only connections from processes running as
+// uid, closing all others.
+type peerCheckListener struct {
+	net.Listener
+	uid int
+	log *log.Logger
+}
+
+// Accept returns the next connection whose peer passes the check.
+func (l peerCheckListener) Accept() (net.Conn, error) {
+	for {
+		c, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		uc, ok := c.(*net.UnixConn)
+		if !ok {
+			c.Close()
+			continue
+		}
+		uid, err := peerUID(uc)
+		if err == nil && uid == l.uid {
+			return c, nil
+		}
+		if err != nil {
+			l.log.Printf("refused connection: %v", err)
+		} else {
+			l.log.Printf("refused connection from uid %d", uid)
+		}
+		c.Close()
+	}
+}
+
+// agentClient returns an HTTP client that dials the agent's socket.
+func agentClient(socket string) *http.Client {
+	return &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return dialAgent(ctx, socket)
+			},
+		},
+	}
+}
+
+// agentRequest sends an API request to the agent on socket. When
+// vaultPath is set, the agent is told which vault the caller expects.
+func agentRequest(socket, method, path string) (*http.Response, error) {
+	req, err := http.NewRequest(method, "http://agent"+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	if vaultPath != "" {
+		abs, err := filepath.Abs(vaultPath)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set(agentVaultHeader, abs)
+	}
+	resp, err := agentClient(socket).Do(req)
+	if errors.Is(err, foreignAgentErr) {
+		return nil, foreignAgentErr
+	}
+	return resp, err
+}
+
+// agentError turns a failed API response into an error.
+func agentError(resp *http.Response) error {
+	var body struct {
+		Error string `json:"error"`
+	}
+	if json.NewDecoder(resp.Body).Decode(&body) == nil && body.Error != "" {
+		return fmt.Errorf("agent: %s", body.Error)
+	}
+	return fmt.Errorf("agent: %s", resp.Status)
+}
+
+// agentStore fetches a copy of the vault held by the agent on socket.
+func agentStore(socket string) (*vault.Store, error) {
+	resp, err := agentRequest(socket, http.MethodGet, "/v1/export")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, agentError(resp)
+	}
+	return vault.ReadJSON(resp.Body)
+}
+
+// openVaultRead is openVault for commands that only read the vault. If
+// $PM_AGENT_SOCK is set and no master password was given, the agent's
+// copy is used. An agent that is not running is skipped silently; one
+// that refuses is reported before falling back.
+func openVaultRead() error {
+	socket := os.Getenv("PM_AGENT_SOCK")
+	if socket == "" || masterPassword != "" {
+		return openVault(false)
+	}
+	s, err := agentStore(socket)
+	if err == nil {
+		store = s
+		return nil
+	}
+	if !agentMissing(err) {
+		fmt.Fprintln(os.Stderr, "**Warning: Not using the agent:", strings.TrimPrefix(err.Error(), "**Error: "))
+	}
+	return openVault(false)
+}
+
+// cmdAgent unlocks the vault and starts the agent, in the background
+// unless -foreground is given.
+func cmdAgent(args []string) error {
+	const use = "usage: agent [-ttl duration] [-socket path] [-foreground]"
+	fs := flag.NewFlagSet("agent", flag.ContinueOnError)
+	ttl := fs.Duration("ttl", time.Hour, "wipe the vault and exit after `duration` (0 for no limit)")
+	socket := fs.String("socket", defaultAgentSocket(), "Unix socket `path` to listen on (default in $XDG_RUNTIME_DIR or a new private directory)")
+	foreground := fs.Bool("foreground", false, "serve in the foreground instead of detaching")
+	if err := fs.Parse(args); err != nil || fs.NArg() != 0 || *ttl < 0 {
+		return usageError(use)
+	}
+	if !agentSupported {
+		return agentUnsupportedErr
+	}
+	if vaultPath == "" {
+		return noVaultErr
+	}
+	abs, err := filepath.Abs(vaultPath)
+	if err != nil {
+		return err
+	}
+	vaultPath = abs
+	if *socket != "" {
+		switch err := pingAgent(*socket); {
+		case err == nil:
+			return agentRunningErr
+		case errors.Is(err, foreignAgentErr):
+			return err
+		}
+	}
+	// Unlocking here checks the password before anything is started.
+	if err := openVault(false); err != nil {
+		return err
+	}
+	if *socket == "" {
+		if *socket, err = privateAgentSocket(); err != nil {
+			return err
+		}
+	}
+	if *foreground {
+		return runAgent(*ttl, *socket)
+	}
+	return startAgent(*ttl, *socket)
+}
+
+// startAgent re-runs this program as a detached foreground agent, handing
+// it the master password on stdin, and prints the shell commands that
+// point later invocations at it.
+func startAgent(ttl time.Duration, socket string) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(exe, "-vault", vaultPath, "agent", "-foreground", "-ttl", ttl.String(), "-socket", socket)
+	cmd.Stdin = strings.NewReader(masterPassword + "\n")
+	for _, kv := range os.Environ() {
+		if !strings.HasPrefix(kv, "PM_MASTER_PASSWORD=") {
+			cmd.Env = append(cmd.Env, kv)
+		}
+	}
+	cmd.SysProcAttr = detachedAttr()
+	if err := cmd.Start(); err != nil {
+		removeAgentSocket(socket)
+		return err
+	}
+	for range 100 {
+		if pingAgent(socket) == nil {
+			fmt.Printf("PM_AGENT_SOCK=%s; export PM_AGENT_SOCK;\n", socket)
+			fmt.Fprintf(os.Stderr, "Agent pid %d", cmd.Process.Pid)
+			if ttl > 0 {
+				fmt.Fprintf(os.Stderr, "; it locks in %s", ttl)
+			}
+			fmt.Fprintln(os.Stderr, ".")
+			return cmd.Process.Release()
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	cmd.Process.Kill()
+	removeAgentSocket(socket)
+	return agentStartErr
+}
+
+// runAgent serves the unlocked store on socket until it is locked, its
+// TTL expires or it is interrupted, then wipes the store.
+func runAgent(ttl time.Duration, socket string) error {
+	ln, err := listen("", socket)
+	if err != nil {
+		return err
+	}
+	defer ln.Close()
+	defer removeAgentSocket(socket)
+
+	logger := log.New(os.Stderr, "pm-agent: ", log.LstdFlags)
+	v, err := newVaultFile(logger)
+	if err != nil {
+		return err
+	}
+	srv := &http.Server{ReadHeaderTimeout: 10 * time.Second}
+	var locked atomic.Bool
+	var once sync.Once
+	lock := func() {
+		once.Do(func() {
+			// Refuse saves first, so nothing can write the wiped store
+			// over the vault file.
+			locked.Store(true)
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			srv.Shutdown(ctx)
+			store.Wipe()
+			masterPassword = ""
+			logger.Print("locked")
+		})
+	}
+	handler := api.New(api.Config{
+		Store: store,
+		Log:   logger,
+		Save: func(s *vault.Store) error {
+			if locked.Load() {
+				return agentLockedErr
+			}
+			return v.save(s)
+		},
+		Lock: lock,
+	})
+	latest := v.guard(handler, func(w http.ResponseWriter) {
+		// Most likely the master password was changed; the agent cannot
+		// follow that, so it locks.
+		go lock()
+		apiReply(w, http.StatusServiceUnavailable, "the vault file changed and could not be reloaded; the agent has locked")
+	})
+	srv.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if p := r.Header.Get(agentVaultHeader); p != "" && p != vaultPath {
+			apiReply(w, http.StatusConflict, "the agent holds a different vault")
+			return
+		}
+		latest.ServeHTTP(w, r)
+	})
+	if ttl > 0 {
+		t := time.AfterFunc(ttl, lock)
+		defer t.Stop()
+	}
+
+	logger.Printf("serving %s on %s", vaultPath, socket)
+	err = serveUntilSignal(srv, peerCheckListener{Listener: ln, uid: os.Getuid(), log: logger})
+	lock()
+	return err
+}
+
+// cmdAgentLock tells the running agent to wipe its copy of the vault and
+// exit.
+func cmdAgentLock(args []string) error {
+	if len(args) != 0 {
+		return usageError("usage: agent-lock")
+	}
+	socket := agentSocketPath()
+	if socket == "" {
+		return noAgentErr
+	}
+	resp, err := agentRequest(socket, http.MethodPost, "/v1/lock")
+	if agentMissing(err) {
+		return noAgentErr
+	}
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		return agentError(resp)
+	}
+	fmt.Fprintln(os.Stderr, "Agent locked.")
+	return nil
+}
@@ -0,0 +1,276 @@
+// api.go
+// HTTP/JSON access to a credential store
+// Zarak Khan
+//
+// Package api serves a vault.Store over HTTP for local tooling:
+//
+//	GET    /v1/entries[?site=s]      list entries, without passwords
+//	GET    /v1/entries/{site}/{user} one entry, with its password
+//	POST   /v1/entries               add an entry (JSON Entry body)
+//	DELETE /v1/entries/{site}/{user} remove one user
+//	DELETE /v1/entries/{site}        remove a site that has a single user
+//	GET    /v1/export                every entry in full (vault.ExportJSON)
+//	POST   /v1/lock                  lock the server, if it supports it
+//
+// Every request must carry "Authorization: Bearer <token>", unless the
+// server has no token because the listener authenticates its clients,
+// and is written to the audit log whether or not it succeeds. Errors are
+// returned as {"error": "..."} with a matching status code.
+
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ZarakL/Go-Projects/vault"
+)
+
+// Entry is the JSON form of a credential. Password is omitted from
+// listings.
+type Entry struct {
+	Site     string   `json:"site"`
+	User     string   `json:"user"`
+	Password string   `json:"password,omitempty"`
+	URL      string   `json:"url,omitempty"`
+	Notes    string   `json:"notes,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+	Modified string   `json:"modified,omitempty"`
+}
+
+// Config configures a Server.
+type Config struct {
+	Store *vault.Store
+	Token string      // bearer token; "" disables token checks
+	Log   *log.Logger // audit log
+	// Save, if set, persists the store after every change. A change that
+	// cannot be saved is reported as a server error.
+	Save func(*vault.Store) error
+	// Lock, if set, handles POST /v1/lock, typically by wiping the store
+	// and shutting the server down.
+	Lock func()
+}
+
+// Server is an http.Handler for the API. Any number of requests may run
+// concurrently: the store does its own locking, and changes are
+// serialized so each is saved (or undone) before the next begins.
+type Server struct {
+	cfg Config
+	mux *http.ServeMux
+	mu  sync.Mutex // held while changing and saving the store
+}
+
+// New returns a Server for cfg.
+func New(cfg Config) *Server {
+	s := &Server{cfg: cfg, mux: http.NewServeMux()}
+	s.mux.HandleFunc("GET /v1/entries", s.list)
+	s.mux.HandleFunc("GET /v1/entries/{site}/{user}", s.get)
+	s.mux.HandleFunc("POST /v1/entries", s.add)
+	s.mux.HandleFunc("DELETE /v1/entries/{site}/{user}", s.remove)
+	s.mux.HandleFunc("DELETE /v1/entries/{site}", s.remove)
+	s.mux.HandleFunc("GET /v1/export", s.export)
+	if cfg.Lock != nil {
+		s.mux.HandleFunc("POST /v1/lock", s.lock)
+	}
+	return s
+}
+
+// statusWriter records the status code written by a handler.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+// WriteHeader records code before passing it on.
+func (w *statusWriter) WriteHeader(code int) {
+	w.status = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// ServeHTTP authenticates r, dispatches it and logs the outcome.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+	if s.authorized(r) {
+		s.mux.ServeHTTP(sw, r)
+	} else {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeError(sw, http.StatusUnauthorized, "missing or invalid bearer token")
+	}
+	if s.cfg.Log != nil {
+		s.cfg.Log.Printf("%s %s %s %d %s", remote(r), r.Method, r.URL.Path, sw.status, time.Since(start).Round(time.Microsecond))
+	}
+}
+
+// authorized reports whether r carries the configured bearer token.
+func (s *Server) authorized(r *http.Request) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if s.cfg.Token == "" {
+		return true
+	}
+	return ok && subtle.ConstantTimeCompare([]byte(token), []byte(s.cfg.Token)) == 1
+}
+
+// remote names the client for the audit log. Requests over a Unix socket
+// have no remote address.
+func remote(r *http.Request) string {
+	if r.RemoteAddr == "" || r.RemoteAddr == "@" {
+		return "unix"
+	}
+	return r.RemoteAddr
+}
+
+// toEntry converts e, including the password only if withPassword.
+func toEntry(e vault.Entry, withPassword bool) Entry {
+	out := Entry{Site: e.Site, User: e.User, URL: e.URL, Notes: e.Notes, Tags: e.Tags}
+	if withPassword {
+		out.Password = e.Password
+	}
+	if !e.Modified.IsZero() {
+		out.Modified = e.Modified.UTC().Format(time.RFC3339)
+	}
+	return out
+}
+
+// list handles GET /v1/entries.
+func (s *Server) list(w http.ResponseWriter, r *http.Request) {
+	entries := s.cfg.Store.List()
+	if site := r.URL.Query().Get("site"); site != "" {
+		var err error
+		if entries, err = s.cfg.Store.Site(site); err != nil {
+			writeStoreError(w, err)
+			return
+		}
+	}
+
+	out := make([]Entry, 0, len(entries))
+	for _, e := range entries {
+		out = append(out, toEntry(e, false))
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// get handles GET /v1/entries/{site}/{user}.
+func (s *Server) get(w http.ResponseWriter, r *http.Request) {
+	e, err := s.cfg.Store.Get(r.PathValue("site"), r.PathValue("user"))
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, toEntry(e, true))
+}
+
+// add handles POST /v1/entries.
+func (s *Server) add(w http.ResponseWriter, r *http.Request) {
+	var in Entry
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&in); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body: "+err.Error())
+		return
+	}
+	if in.Site == "" || in.User == "" || in.Password == "" {
+		writeError(w, http.StatusBadRequest, "site, user and password are required")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := s.cfg.Store
+	if err := st.Add(in.Site, in.User, in.Password); err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	if in.URL != "" || in.Notes != "" || len(in.Tags) > 0 {
+		st.SetMeta(in.Site, in.User, vault.Meta{URL: in.URL, Notes: in.Notes, Tags: in.Tags})
+	}
+	if err := s.save(); err != nil {
+		// Keep the store in step with the file.
+		st.Remove(in.Site, in.User)
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	e, _ := st.Get(in.Site, in.User)
+	writeJSON(w, http.StatusCreated, toEntry(e, false))
+}
+
+// remove handles DELETE /v1/entries/{site}[/{user}]. If the change cannot
+// be saved, the entry is put back.
+func (s *Server) remove(w http.ResponseWriter, r *http.Request) {
+	site, user := r.PathValue("site"), r.PathValue("user")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := s.cfg.Store
+	removed, err := st.Get(site, user)
+	if err == nil {
+		err = st.Remove(site, user)
+	}
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	if err := s.save(); err != nil {
+		st.Restore(removed)
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// export handles GET /v1/export.
+func (s *Server) export(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	s.cfg.Store.ExportJSON(w)
+}
+
+// lock handles POST /v1/lock. The response is sent before Lock runs, so
+// a Lock that shuts the server down does not cut it off.
+func (s *Server) lock(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusNoContent)
+	http.NewResponseController(w).Flush()
+	go s.cfg.Lock()
+}
+
+// save persists the store, if configured. The caller holds s.mu.
+func (s *Server) save() error {
+	if s.cfg.Save == nil {
+		return nil
+	}
+	return s.cfg.Save(s.cfg.Store)
+}
+
+// writeStoreError maps a vault error to an HTTP status.
+func writeStoreError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, vault.ErrSiteNotFound), errors.Is(err, vault.ErrUserNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, vault.ErrDuplicate), errors.Is(err, vault.ErrAmbiguousRemove), errors.Is(err, vault.ErrAmbiguousUser):
+		status = http.StatusConflict
+	}
+	writeError(w, status, strings.TrimPrefix(err.Error(), "vault: "))
+}
+
+// writeError sends {"error": msg} with status.
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
+
+// writeJSON sends v as the JSON response body with status.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
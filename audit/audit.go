@@ -0,0 +1,174 @@
+// audit.go
+// Password strength, reuse and age checks
+// Zarak Khan
+//
+// Package audit inspects the passwords in a vault and reports the weak,
+// reused and stale ones. Strength is an entropy estimate from the size of
+// the character pool, reduced for repeats and runs such as "aaaa" or
+// "1234"; it is a quick screen, not a cracking-time prediction.
+
+package audit
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/ZarakL/Go-Projects/vault"
+)
+
+// Options set the audit thresholds.
+type Options struct {
+	MinEntropy float64       // bits below which a password is weak
+	MaxAge     time.Duration // password age above which an entry is old (0 to skip)
+	Now        time.Time     // reference time for ages; zero means time.Now()
+}
+
+// DefaultOptions flag passwords under 60 bits or unchanged for a year.
+var DefaultOptions = Options{MinEntropy: 60, MaxAge: 365 * 24 * time.Hour}
+
+// EntryReport is the audit result for one entry.
+type EntryReport struct {
+	Site, User string
+	Entropy    float64
+	Weak       bool
+	Common     bool      // in the list of very common passwords
+	ReusedBy   []string  // other entries with the same password, as user@site
+	Changed    time.Time // when the password was set; zero if unknown
+	Old        bool
+}
+
+// Report summarizes an audit. Entries are sorted by site and user; Reuse
+// lists each group of entries sharing a password.
+type Report struct {
+	Entries        []EntryReport
+	Weak, Old      int
+	Reused         int // entries whose password appears more than once
+	Reuse          [][]string
+	AverageEntropy float64
+}
+
+// commonPasswords are rejected regardless of their estimated entropy.
+var commonPasswords = map[string]bool{
+	"123456": true, "123456789": true, "12345678": true, "password": true,
+	"qwerty": true, "qwerty123": true, "1q2w3e4r": true, "111111": true,
+	"abc123": true, "password1": true, "iloveyou": true, "admin": true,
+	"welcome": true, "monkey": true, "dragon": true, "letmein": true,
+	"football": true, "baseball": true, "sunshine": true, "princess": true,
+	"passw0rd": true, "p@ssw0rd": true, "trustno1": true, "000000": true,
+	"qwertyuiop": true, "master": true, "superman": true, "changeme": true,
+}
+
+// Entropy estimates the strength of pw in bits.
+func Entropy(pw string) float64 {
+	var lower, upper, digit, symbol, other bool
+	for _, r := range pw {
+		switch {
+		case r >= 'a' && r <= 'z':
+			lower = true
+		case r >= 'A' && r <= 'Z':
+			upper = true
+		case r >= '0' && r <= '9':
+			digit = true
+		case r < unicode.MaxASCII:
+			symbol = true
+		default:
+			other = true
+		}
+	}
+	pool := 0
+	for _, c := range []struct {
+		on   bool
+		size int
+	}{{lower, 26}, {upper, 26}, {digit, 10}, {symbol, 33}, {other, 100}} {
+		if c.on {
+			pool += c.size
+		}
+	}
+	if pool == 0 {
+		return 0
+	}
+
+	// A character repeating the previous one, or continuing a run of
+	// consecutive code points, adds only a bit.
+	var effective float64
+	var prev, step rune
+	for i, r := range []rune(pw) {
+		switch d := r - prev; {
+		case i == 0:
+			effective++
+		case d == 0 || (i > 1 && d == step && (d == 1 || d == -1)):
+			effective += 1 / math.Log2(float64(pool))
+		default:
+			effective++
+		}
+		if i > 0 {
+			step = r - prev
+		}
+		prev = r
+	}
+	return effective * math.Log2(float64(pool))
+}
+
+// label names an entry in reuse groups.
+func label(e vault.Entry) string {
+	return e.User + "@" + e.Site
+}
+
+// Run audits entries.
+func Run(entries vault.EntrySlice, o Options) Report {
+	if o.Now.IsZero() {
+		o.Now = time.Now()
+	}
+	entries = append(vault.EntrySlice(nil), entries...)
+	entries.Sort(vault.BySite)
+
+	byPassword := make(map[string][]string)
+	for _, e := range entries {
+		byPassword[e.Password] = append(byPassword[e.Password], label(e))
+	}
+
+	var rep Report
+	var total float64
+	for _, e := range entries {
+		r := EntryReport{
+			Site:    e.Site,
+			User:    e.User,
+			Entropy: Entropy(e.Password),
+			Common:  commonPasswords[strings.ToLower(e.Password)],
+			Changed: e.PasswordChanged(),
+		}
+		r.Weak = r.Common || r.Entropy < o.MinEntropy
+		r.Old = o.MaxAge > 0 && !r.Changed.IsZero() && o.Now.Sub(r.Changed) > o.MaxAge
+		for _, other := range byPassword[e.Password] {
+			if other != label(e) {
+				r.ReusedBy = append(r.ReusedBy, other)
+			}
+		}
+
+		total += r.Entropy
+		if r.Weak {
+			rep.Weak++
+		}
+		if r.Old {
+			rep.Old++
+		}
+		if len(r.ReusedBy) > 0 {
+			rep.Reused++
+		}
+		rep.Entries = append(rep.Entries, r)
+	}
+	if len(entries) > 0 {
+		rep.AverageEntropy = total / float64(len(entries))
+	}
+
+	for _, group := range byPassword {
+		if len(group) > 1 {
+			rep.Reuse = append(rep.Reuse, group)
+		}
+	}
+	sort.Slice(rep.Reuse, func(i, j int) bool { return rep.Reuse[i][0] < rep.Reuse[j][0] })
+	return rep
+}
@@ -0,0 +1,108 @@
+// auditreport.go
+// Text and JSON output for the audit command
+// Zarak Khan
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ZarakL/Go-Projects/audit"
+)
+
+// writeAuditText prints the audit summary followed by a section for each
+// kind of problem found.
+func writeAuditText(w io.Writer, rep audit.Report, o audit.Options) error {
+	fmt.Fprintf(w, "Audited %d entries: %d weak, %d reused", len(rep.Entries), rep.Weak, rep.Reused)
+	if o.MaxAge > 0 {
+		fmt.Fprintf(w, ", %d unchanged for over %d days", rep.Old, int(o.MaxAge.Hours()/24))
+	}
+	fmt.Fprintf(w, ".\nAverage strength %.0f bits.\n", rep.AverageEntropy)
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	if rep.Weak > 0 {
+		fmt.Fprintln(tw, "\nWeak passwords:")
+		for _, e := range rep.Entries {
+			if !e.Weak {
+				continue
+			}
+			why := fmt.Sprintf("%.0f bits", e.Entropy)
+			if e.Common {
+				why += ", common password"
+			}
+			fmt.Fprintf(tw, "  %s@%s\t%s\n", e.User, e.Site, why)
+		}
+	}
+	if len(rep.Reuse) > 0 {
+		fmt.Fprintln(tw, "\nReused passwords:")
+		for _, group := range rep.Reuse {
+			fmt.Fprintf(tw, "  %s\n", strings.Join(group, ", "))
+		}
+	}
+	if rep.Old > 0 {
+		fmt.Fprintln(tw, "\nOld passwords:")
+		for _, e := range rep.Entries {
+			if e.Old {
+				fmt.Fprintf(tw, "  %s@%s\tlast changed %s\n", e.User, e.Site, formatTime(e.Changed))
+			}
+		}
+	}
+	return tw.Flush()
+}
+
+// auditRecord is one entry in JSON audit output. Passwords are never
+// included.
+type auditRecord struct {
+	Site     string   `json:"site"`
+	User     string   `json:"user"`
+	Entropy  int      `json:"entropy_bits"`
+	Weak     bool     `json:"weak"`
+	Common   bool     `json:"common,omitempty"`
+	ReusedBy []string `json:"reused_by,omitempty"`
+	Changed  string   `json:"changed,omitempty"`
+	Old      bool     `json:"old"`
+}
+
+// auditSummary is the top level of JSON audit output.
+type auditSummary struct {
+	Total          int           `json:"total"`
+	Weak           int           `json:"weak"`
+	Reused         int           `json:"reused"`
+	Old            int           `json:"old"`
+	MaxAgeDays     int           `json:"max_age_days,omitempty"`
+	AverageEntropy int           `json:"average_entropy_bits"`
+	ReuseGroups    [][]string    `json:"reuse_groups"`
+	Entries        []auditRecord `json:"entries"`
+}
+
+// writeAuditJSON prints the audit as an indented JSON object.
+func writeAuditJSON(w io.Writer, rep audit.Report, o audit.Options) error {
+	out := auditSummary{
+		Total:          len(rep.Entries),
+		Weak:           rep.Weak,
+		Reused:         rep.Reused,
+		Old:            rep.Old,
+		MaxAgeDays:     int(o.MaxAge / (24 * time.Hour)),
+		AverageEntropy: int(rep.AverageEntropy + 0.5),
+		ReuseGroups:    rep.Reuse,
+		Entries:        []auditRecord{},
+	}
+	if out.ReuseGroups == nil {
+		out.ReuseGroups = [][]string{}
+	}
+	for _, e := range rep.Entries {
+		out.Entries = append(out.Entries, auditRecord{
+			Site: e.Site, User: e.User,
+			Entropy: int(e.Entropy + 0.5), Weak: e.Weak, Common: e.Common,
+			ReusedBy: e.ReusedBy, Changed: modifiedTime(e.Changed), Old: e.Old,
+		})
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
@@ -0,0 +1,139 @@
+// breach.go
+// Offline lookups in a sorted list of breached password hashes
+// Zarak Khan
+//
+// Package breach checks passwords against a local copy of a breached
+// password corpus such as the Have I Been Pwned SHA-1 download. The file
+// holds one "HASH:COUNT" line per password, sorted by the upper-case hex
+// SHA-1 hash; the ":COUNT" part is optional. Lookups binary-search the
+// file by byte offset, so even multi-gigabyte lists need no index and no
+// network access.
+
+package breach
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ErrHash is returned by LookupHash for a malformed SHA-1 hash.
+var ErrHash = errors.New("breach: not a hex SHA-1 hash")
+
+// List is an open breached-password hash list.
+type List struct {
+	f    *os.File
+	size int64
+}
+
+// Open opens the hash list at path.
+func Open(path string) (*List, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &List{f: f, size: fi.Size()}, nil
+}
+
+// Close closes the underlying file.
+func (l *List) Close() error {
+	return l.f.Close()
+}
+
+// Hash returns the upper-case hex SHA-1 hash of password, as used in the
+// list.
+func Hash(password string) string {
+	sum := sha1.Sum([]byte(password))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+// Lookup reports how many times password appears in the breach corpus,
+// or 0 if it is not listed.
+func (l *List) Lookup(password string) (int, error) {
+	return l.LookupHash(Hash(password))
+}
+
+// LookupHash is Lookup for a password that is already hashed.
+func (l *List) LookupHash(hash string) (int, error) {
+	hash = strings.ToUpper(hash)
+	if len(hash) != 2*sha1.Size {
+		return 0, ErrHash
+	}
+	if _, err := hex.DecodeString(hash); err != nil {
+		return 0, ErrHash
+	}
+
+	// Invariant: if hash is listed, its line starts in [lo, hi).
+	lo, hi := int64(0), l.size
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		start, next, line, err := l.lineAt(mid)
+		if err != nil {
+			return 0, err
+		}
+		if start >= hi {
+			hi = mid
+			continue
+		}
+		key, count, _ := strings.Cut(line, ":")
+		switch c := strings.Compare(hash, strings.ToUpper(key)); {
+		case c == 0:
+			if count == "" {
+				return 1, nil
+			}
+			n, err := strconv.Atoi(strings.TrimSpace(count))
+			if err != nil || n < 1 {
+				return 1, nil
+			}
+			return n, nil
+		case c < 0:
+			hi = mid
+		default:
+			lo = next
+		}
+	}
+	return 0, nil
+}
+
+// lineAt returns the first line that starts at or after off, its start
+// offset and the offset of the line after it. At the end of the file
+// start is l.size.
+func (l *List) lineAt(off int64) (start, next int64, line string, err error) {
+	start = off
+	if off > 0 {
+		// off starts a line only if the byte before it ends one.
+		start = off - 1
+	}
+	br := bufio.NewReaderSize(io.NewSectionReader(l.f, start, l.size-start), 128)
+	if off > 0 {
+		for {
+			chunk, err := br.ReadSlice('\n')
+			start += int64(len(chunk))
+			if err == nil {
+				break
+			}
+			if err == io.EOF {
+				return l.size, l.size, "", nil
+			}
+			if err != bufio.ErrBufferFull {
+				return 0, 0, "", err
+			}
+		}
+	}
+	line, err = br.ReadString('\n')
+	if err == io.EOF {
+		err = nil
+	}
+	next = start + int64(len(line))
+	return start, next, strings.TrimRight(line, "\r\n"), err
+}
@@ -0,0 +1,74 @@
+// breachcheck.go
+// Checking stored passwords against a local breached-password list
+// Zarak Khan
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/ZarakL/Go-Projects/breach"
+	"github.com/ZarakL/Go-Projects/vault"
+)
+
+// breachListPath names the sorted SHA-1 hash list used by breach-check
+// and the listing ("" when none is configured).
+var breachListPath string
+
+var noBreachListErr = usageError("No breach list given. Use -breach-list or set PM_BREACH_LIST.")
+
+// entryKey identifies an entry in lookups keyed by site and user.
+type entryKey struct {
+	site, user string
+}
+
+// breachHits looks up every entry's password in the breach list and
+// returns how often each listed one was seen. It returns nil when no list
+// is configured.
+func breachHits(entries vault.EntrySlice) (map[entryKey]int, error) {
+	if breachListPath == "" {
+		return nil, nil
+	}
+	l, err := breach.Open(breachListPath)
+	if err != nil {
+		return nil, err
+	}
+	defer l.Close()
+
+	hits := make(map[entryKey]int)
+	for _, e := range entries {
+		n, err := l.Lookup(e.Password)
+		if err != nil {
+			return nil, err
+		}
+		if n > 0 {
+			hits[entryKey{e.Site, e.User}] = n
+		}
+	}
+	return hits, nil
+}
+
+// cmdBreachCheck reports every stored password found in the breach list.
+func cmdBreachCheck(args []string) error {
+	if len(args) != 0 {
+		return usageError("usage: breach-check")
+	}
+	if breachListPath == "" {
+		return noBreachListErr
+	}
+	if err := openVaultRead(); err != nil {
+		return err
+	}
+	entries := store.List()
+	hits, err := breachHits(entries)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if n, ok := hits[entryKey{e.Site, e.User}]; ok {
+			fmt.Printf("%s@%s: seen %d times in breaches\n", e.User, e.Site, n)
+		}
+	}
+	fmt.Printf("%d of %d passwords found in the breach list.\n", len(hits), len(entries))
+	return nil
+}
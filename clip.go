@@ -0,0 +1,53 @@
+// clip.go
+// Copying passwords to the clipboard without printing them
+// Zarak Khan
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/ZarakL/Go-Projects/clipboard"
+)
+
+// clipboardName selects the clipboard backend ("" to auto-detect) and
+// clipTimeout how long a copied password stays on the clipboard.
+var (
+	clipboardName string
+	clipTimeout   = 45 * time.Second
+)
+
+// clip clears the clipboard once clipTimeout has passed.
+var clip clipboard.Clearer
+
+// clipboardBackend returns the configured backend.
+func clipboardBackend() (clipboard.Backend, error) {
+	if clipboardName == "" {
+		return clipboard.Detect(), nil
+	}
+	return clipboard.Lookup(clipboardName)
+}
+
+// copyEntry places the password for site and user on the clipboard and
+// schedules it to be cleared.
+func copyEntry(site, user string) error {
+	e, err := store.Get(site, user)
+	if err != nil {
+		return err
+	}
+	b, err := clipboardBackend()
+	if err != nil {
+		return err
+	}
+	if err := clip.CopyFor(b, e.Password, clipTimeout); err != nil {
+		return err
+	}
+	msg := fmt.Sprintf("Copied the password for %s at %s to the clipboard (%s)", e.User, e.Site, b.Name())
+	if clipTimeout > 0 {
+		msg += fmt.Sprintf("; it will be cleared in %s", clipTimeout)
+	}
+	fmt.Fprintln(os.Stderr, msg+".")
+	return nil
+}
@@ -0,0 +1,190 @@
+// clipboard.go
+// Pluggable system clipboard access
+// Zarak Khan
+//
+// Package clipboard places text on the system clipboard through one of
+// several backends: the wl-copy and xclip helpers, the OSC 52 terminal
+// escape sequence, or a plain file that stands in for the clipboard in
+// tests and headless setups.
+
+package clipboard
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrUnknownBackend is returned by Lookup for an unrecognized name.
+var ErrUnknownBackend = errors.New("clipboard: unknown backend")
+
+// Backend writes to and clears a clipboard.
+type Backend interface {
+	Name() string
+	Copy(text string) error
+	Clear() error
+}
+
+// command is a backend that pipes text into an external helper.
+type command struct {
+	name      string
+	copyArgs  []string
+	clearArgs []string // nil means clear by copying an empty string
+}
+
+// WLCopy uses wl-copy on Wayland desktops.
+var WLCopy Backend = command{name: "wl-copy", copyArgs: []string{"wl-copy"}, clearArgs: []string{"wl-copy", "--clear"}}
+
+// XClip uses xclip on X11 desktops.
+var XClip Backend = command{name: "xclip", copyArgs: []string{"xclip", "-selection", "clipboard"}}
+
+func (c command) Name() string { return c.name }
+
+func (c command) Copy(text string) error {
+	return c.run(c.copyArgs, text)
+}
+
+func (c command) Clear() error {
+	if c.clearArgs != nil {
+		return c.run(c.clearArgs, "")
+	}
+	return c.run(c.copyArgs, "")
+}
+
+// run executes args with text on standard input.
+func (c command) run(args []string, text string) error {
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = strings.NewReader(text)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("clipboard: %s: %v %s", c.name, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// OSC52 asks the terminal itself to set the clipboard, which also works
+// over SSH. Not every terminal supports it.
+type OSC52 struct {
+	W io.Writer
+}
+
+func (o OSC52) Name() string { return "osc52" }
+
+func (o OSC52) Copy(text string) error {
+	_, err := fmt.Fprintf(o.W, "\x1b]52;c;%s\a", base64.StdEncoding.EncodeToString([]byte(text)))
+	return err
+}
+
+func (o OSC52) Clear() error {
+	return o.Copy("")
+}
+
+// File keeps the clipboard contents in a file. It is meant for tests and
+// for environments without a real clipboard.
+type File struct {
+	Path string
+}
+
+func (f File) Name() string { return "file:" + f.Path }
+
+func (f File) Copy(text string) error {
+	return os.WriteFile(f.Path, []byte(text), 0600)
+}
+
+func (f File) Clear() error {
+	return os.WriteFile(f.Path, nil, 0600)
+}
+
+// Lookup returns the backend with the given name: "wl-copy", "xclip",
+// "osc52" or "file:PATH".
+func Lookup(name string) (Backend, error) {
+	switch {
+	case name == "wl-copy":
+		return WLCopy, nil
+	case name == "xclip":
+		return XClip, nil
+	case name == "osc52":
+		return OSC52{W: os.Stderr}, nil
+	case strings.HasPrefix(name, "file:"):
+		return File{Path: strings.TrimPrefix(name, "file:")}, nil
+	}
+	return nil, ErrUnknownBackend
+}
+
+// Detect picks a backend for the current environment: wl-copy under
+// Wayland, xclip under X11, otherwise OSC 52.
+func Detect() Backend {
+	if _, err := exec.LookPath("wl-copy"); err == nil && os.Getenv("WAYLAND_DISPLAY") != "" {
+		return WLCopy
+	}
+	if _, err := exec.LookPath("xclip"); err == nil && os.Getenv("DISPLAY") != "" {
+		return XClip
+	}
+	return OSC52{W: os.Stderr}
+}
+
+// Clearer clears a backend after a delay. Copying again restarts the
+// countdown.
+type Clearer struct {
+	mu    sync.Mutex
+	b     Backend
+	timer *time.Timer
+	gen   uint64 // counts timers started, so a stale one can tell
+}
+
+// CopyFor copies text to b and schedules it to be cleared after d. A zero
+// d leaves the text on the clipboard.
+func (c *Clearer) CopyFor(b Backend, text string, d time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.timer != nil {
+		c.timer.Stop()
+		c.timer = nil
+	}
+	if err := b.Copy(text); err != nil {
+		return err
+	}
+	c.b = b
+	if d > 0 {
+		c.gen++
+		gen := c.gen
+		c.timer = time.AfterFunc(d, func() { c.expire(gen) })
+	}
+	return nil
+}
+
+// expire clears the clipboard when the timer of copy gen fires, unless a
+// later CopyFor or Flush has replaced or cancelled it in the meantime.
+func (c *Clearer) expire(gen uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.timer == nil || c.gen != gen {
+		return
+	}
+	c.timer = nil
+	c.b.Clear()
+}
+
+// Flush clears the clipboard now if a clear is pending.
+func (c *Clearer) Flush() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.timer == nil {
+		return nil
+	}
+	c.timer.Stop()
+	c.timer = nil
+	return c.b.Clear()
+}
+
+// Pending reports whether a clear is scheduled.
+func (c *Clearer) Pending() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.timer != nil
+}
@@ -0,0 +1,128 @@
+// clipboard_test.go
+// Delayed clearing, checked through the file backend
+// Zarak Khan
+
+package clipboard
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// contents returns what the file backend currently holds.
+func contents(t *testing.T, f File) string {
+	t.Helper()
+	data, err := os.ReadFile(f.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// waitEmpty waits up to limit for the file backend to be cleared.
+func waitEmpty(t *testing.T, f File, limit time.Duration) bool {
+	t.Helper()
+	for end := time.Now().Add(limit); time.Now().Before(end); time.Sleep(5 * time.Millisecond) {
+		if contents(t, f) == "" {
+			return true
+		}
+	}
+	return false
+}
+
+func TestCopyForClearsAfterDelay(t *testing.T) {
+	f := File{Path: filepath.Join(t.TempDir(), "clip")}
+	var c Clearer
+	if err := c.CopyFor(f, "secret", 50*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if got := contents(t, f); got != "secret" {
+		t.Fatalf("clipboard holds %q, want secret", got)
+	}
+	if !c.Pending() {
+		t.Fatal("no clear pending after CopyFor")
+	}
+	if !waitEmpty(t, f, 2*time.Second) {
+		t.Fatal("clipboard was not cleared")
+	}
+	if c.Pending() {
+		t.Error("clear still pending after it ran")
+	}
+}
+
+func TestFlushClearsEarly(t *testing.T) {
+	f := File{Path: filepath.Join(t.TempDir(), "clip")}
+	var c Clearer
+	if err := c.CopyFor(f, "secret", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if got := contents(t, f); got != "" {
+		t.Errorf("clipboard holds %q after Flush", got)
+	}
+	if c.Pending() {
+		t.Error("clear still pending after Flush")
+	}
+}
+
+func TestZeroDelayKeepsText(t *testing.T) {
+	f := File{Path: filepath.Join(t.TempDir(), "clip")}
+	var c Clearer
+	if err := c.CopyFor(f, "kept", 0); err != nil {
+		t.Fatal(err)
+	}
+	if c.Pending() {
+		t.Error("clear pending with a zero delay")
+	}
+	if err := c.Flush(); err != nil || contents(t, f) != "kept" {
+		t.Errorf("Flush without a pending clear changed the clipboard (err %v)", err)
+	}
+}
+
+func TestCopyAgainRestartsCountdown(t *testing.T) {
+	f := File{Path: filepath.Join(t.TempDir(), "clip")}
+	var c Clearer
+	if err := c.CopyFor(f, "first", 100*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(60 * time.Millisecond)
+	if err := c.CopyFor(f, "second", 300*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	// The first countdown would have run out by now.
+	time.Sleep(100 * time.Millisecond)
+	if got := contents(t, f); got != "second" {
+		t.Fatalf("clipboard holds %q, want second", got)
+	}
+	if !waitEmpty(t, f, 2*time.Second) {
+		t.Fatal("clipboard was not cleared after the second countdown")
+	}
+}
+
+// TestStaleTimerKeepsNewText fires an old timer's callback after a new
+// copy has replaced it, as happens when it races with CopyFor.
+func TestStaleTimerKeepsNewText(t *testing.T) {
+	f := File{Path: filepath.Join(t.TempDir(), "clip")}
+	var c Clearer
+	if err := c.CopyFor(f, "first", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	c.mu.Lock()
+	old := c.gen
+	c.mu.Unlock()
+	if err := c.CopyFor(f, "second", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	c.expire(old)
+	if got := contents(t, f); got != "second" {
+		t.Errorf("stale timer cleared the clipboard: holds %q", got)
+	}
+	if !c.Pending() {
+		t.Error("stale timer cancelled the new countdown")
+	}
+	c.Flush()
+}
@@ -0,0 +1,749 @@
+// commands.go
+// Non-interactive subcommands for the password manager
+// Zarak Khan
+//
+// Usage: pm [-vault file | -use name] [-clipboard name] [-clip-timeout d] [-lock-after d] [-breach-list file] <command> [flags] [args]
+//
+// The vault may also be named by $PM_VAULT, or a named vault (see
+// vaults.go) chosen by -use or $PM_VAULT_NAME, and the master password is
+// taken from $PM_MASTER_PASSWORD or prompted for (without echo on a
+// terminal, or as the first line of piped stdin).
+// Exit status is 0 on success, 1 on failure, 2 on a usage error and 3
+// when the requested site or user does not exist (or a search finds
+// nothing).
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ZarakL/Go-Projects/audit"
+	"github.com/ZarakL/Go-Projects/passgen"
+	"github.com/ZarakL/Go-Projects/vault"
+)
+
+const (
+	exitOK       = 0
+	exitError    = 1
+	exitUsage    = 2
+	exitNotFound = 3
+)
+
+// usageError is returned by a command that was invoked incorrectly.
+type usageError string
+
+func (e usageError) Error() string { return "**Error: " + string(e) }
+
+// errReported fails a command whose error was already shown to the user.
+var errReported = errors.New("")
+
+var (
+	noVaultErr = usageError("No vault given. Use -vault or set PM_VAULT.")
+	noMatchErr = errors.New("**Error: No matching entries.")
+
+	exportCancelledErr = errors.New("**Error: Export cancelled.")
+)
+
+// stdin is shared by every prompt so buffered input is never lost between
+// readers.
+var stdin = bufio.NewReader(os.Stdin)
+
+// command is one subcommand. run receives the arguments after the
+// command name.
+type command struct {
+	name, args, summary string
+	run                 func(args []string) error
+}
+
+var commands []command
+
+func init() {
+	commands = []command{
+		{"list", "[-show] [-sort key] [-format fmt] [site]", "list stored credentials (passwords masked unless -show)", cmdList},
+		{"add", "[-password pass | -generate] [meta flags] site user", "add a credential (password read from stdin if not given)", cmdAdd},
+		{"history", "[-show] site user", "list an entry's previous passwords", cmdHistory},
+		{"rollback", "site user [n]", "restore the password from n changes ago (default 1)", cmdRollback},
+		{"remove", "site [user]", "remove a site or one of its users", cmdRemove},
+		{"update", "[-user name] [-password pass | -generate] [meta flags] site user", "change a user's password and/or name", cmdUpdate},
+		{"get", "site [user]", "print a single password", cmdGet},
+		{"show", "[-reveal] site [user]", "show all details of one entry", cmdShow},
+		{"totp", "site [user]", "print an entry's current two-factor code", cmdTOTP},
+		{"search", "[-glob | -fuzzy] [-tag tag] [text]", "find entries by site, user, tags and notes", cmdSearch},
+		{"copy", "site [user]", "copy a password to the clipboard, then clear it", cmdCopy},
+		{"import", "[-format fmt] [-map spec] [-on-duplicate policy] file", "import a plaintext \"site user pass\" file or a CSV export", cmdImport},
+		{"export", "[-format legacy|csv|json | -encrypt] [-o file]", "write all credentials out, in plaintext after confirmation", cmdExport},
+		{"audit", "[-min-bits n] [-max-age days] [-format text|json]", "report weak, reused and old passwords", cmdAudit},
+		{"breach-check", "", "look up every password in the -breach-list hash file", cmdBreachCheck},
+		{"passwd", "[-logn n] [-r n] [-p n]", "change the master password and optionally the scrypt cost", cmdPasswd},
+		{"generate", "[-length n] [-words n]", "print a random password or passphrase", cmdGenerate},
+		{"serve", "[-addr host:port | -socket path] [-token-file f] [-audit-log f]", "serve the vault over a local HTTP/JSON API", cmdServe},
+		{"agent", "[-ttl d] [-socket path] [-foreground]", "unlock once and keep the vault in a background agent", cmdAgent},
+		{"agent-lock", "", "wipe the agent's copy of the vault and stop it", cmdAgentLock},
+		{"vaults", "", "list the named vaults (see -use)", cmdVaults},
+		{"move", "-to name site [user]", "move a site or one user to another named vault", cmdMove},
+		{"shell", "", "start the interactive menu (default)", cmdShell},
+	}
+}
+
+// usage prints the global help text to stderr.
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: pm [-vault file | -use name] [-clipboard name] [-clip-timeout d] [-lock-after d] [-breach-list file] <command> [flags] [args]")
+	fmt.Fprintln(os.Stderr, "\nCommands:")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-12s %-65s %s\n", c.name, c.args, c.summary)
+	}
+	fmt.Fprintln(os.Stderr, "\nMeta flags: -url URL -notes text -tags a,b -field name=value")
+	fmt.Fprintln(os.Stderr, "            -secret-field name=value -delete-field name -totp secret")
+}
+
+// run parses the global flags, dispatches to a command and returns the
+// process exit code.
+func run(args []string) int {
+	fs := flag.NewFlagSet("pm", flag.ContinueOnError)
+	fs.StringVar(&vaultPath, "vault", os.Getenv("PM_VAULT"), "vault `file` to operate on")
+	fs.StringVar(&clipboardName, "clipboard", os.Getenv("PM_CLIPBOARD"), "clipboard backend: wl-copy, xclip, osc52 or file:PATH")
+	fs.DurationVar(&clipTimeout, "clip-timeout", clipTimeout, "clear copied passwords after `duration` (0 to keep)")
+	fs.StringVar(&breachListPath, "breach-list", os.Getenv("PM_BREACH_LIST"), "sorted SHA-1 breached password `file` to check against")
+	fs.DurationVar(&lockAfter, "lock-after", lockAfter, "lock the shell after `duration` idle at the menu (0 to never)")
+	useName := fs.String("use", os.Getenv("PM_VAULT_NAME"), "named `vault` to operate on instead of -vault (see vaults)")
+	fs.Usage = usage
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	if set["vault"] && set["use"] {
+		return exitCode(useAndVaultErr)
+	}
+	if *useName != "" && !set["vault"] {
+		path, err := namedVaultPath(*useName)
+		if err != nil {
+			return exitCode(err)
+		}
+		vaultPath = path
+	}
+	masterPassword = os.Getenv("PM_MASTER_PASSWORD")
+
+	name, rest := "shell", fs.Args()
+	if len(rest) > 0 {
+		name, rest = rest[0], rest[1:]
+	}
+	for _, c := range commands {
+		if c.name == name {
+			return exitCode(c.run(rest))
+		}
+	}
+	fmt.Fprintf(os.Stderr, "**Error: Unknown command %q.\n", name)
+	usage()
+	return exitUsage
+}
+
+// exitCode reports err on stderr and maps it to an exit status.
+func exitCode(err error) int {
+	if err == nil {
+		return exitOK
+	}
+	if err != errReported {
+		fmt.Fprintln(os.Stderr, errorText(err))
+	}
+
+	var ue usageError
+	switch {
+	case errors.As(err, &ue):
+		return exitUsage
+	case errors.Is(err, vault.ErrSiteNotFound), errors.Is(err, vault.ErrUserNotFound), errors.Is(err, noMatchErr):
+		return exitNotFound
+	}
+	return exitError
+}
+
+// readLine reads one line from stdin without its line ending.
+func readLine() (string, error) {
+	line, err := stdin.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// openVault loads the vault named by -vault into store. With create set,
+// a missing file is not an error and yields an empty store.
+func openVault(create bool) error {
+	if vaultPath == "" {
+		return noVaultErr
+	}
+	_, err := os.Stat(vaultPath)
+	isNew := create && os.IsNotExist(err)
+	if masterPassword == "" {
+		read := readPassword
+		if isNew {
+			read = readNewPassword
+		}
+		pw, err := read(os.Stderr, "Master password: ")
+		if err != nil {
+			return err
+		}
+		masterPassword = pw
+	}
+	if isNew {
+		return nil
+	}
+	s, err := vault.Open(vaultPath, masterPassword)
+	if err != nil {
+		return err
+	}
+	store = s
+	return nil
+}
+
+// commit writes store back to the vault if it was changed.
+func commit() error {
+	if !dirty {
+		return nil
+	}
+	if err := store.Save(vaultPath, masterPassword); err != nil {
+		return err
+	}
+	dirty = false
+	return nil
+}
+
+// cmdList prints every credential, or only those for one site.
+func cmdList(args []string) error {
+	o, rest, err := parseListFlags(args)
+	if err != nil {
+		return err
+	}
+	if len(rest) > 1 {
+		return usageError("usage: list [-show] [-sort site|user|modified] [-format text|table|json|csv] [site]")
+	}
+	if err := openVaultRead(); err != nil {
+		return err
+	}
+	if len(rest) == 1 {
+		slice, err := store.Site(rest[0])
+		if err != nil {
+			return err
+		}
+		return printListing(slice, o)
+	}
+	return listAll(o)
+}
+
+// genFlags are the password generator flags shared by add and generate.
+type genFlags struct {
+	length                                int
+	noLower, noUpper, noDigits, noSymbols bool
+	noAmbiguous                           bool
+	words                                 int
+}
+
+// register adds the generator flags to fs.
+func (g *genFlags) register(fs *flag.FlagSet) {
+	fs.IntVar(&g.length, "length", passgen.DefaultOptions.Length, "generated password length")
+	fs.BoolVar(&g.noLower, "no-lower", false, "omit lowercase letters")
+	fs.BoolVar(&g.noUpper, "no-upper", false, "omit uppercase letters")
+	fs.BoolVar(&g.noDigits, "no-digits", false, "omit digits")
+	fs.BoolVar(&g.noSymbols, "no-symbols", false, "omit symbols")
+	fs.BoolVar(&g.noAmbiguous, "no-ambiguous", false, "omit look-alike characters such as l, 1, O and 0")
+	fs.IntVar(&g.words, "words", 0, "generate a passphrase of `n` words instead")
+}
+
+// generate produces a password or passphrase as selected by the flags.
+func (g *genFlags) generate() (string, error) {
+	if g.words > 0 {
+		return passgen.Passphrase(g.words, "-")
+	}
+	return passgen.Password(passgen.Options{
+		Length:           g.length,
+		Lower:            !g.noLower,
+		Upper:            !g.noUpper,
+		Digits:           !g.noDigits,
+		Symbols:          !g.noSymbols,
+		ExcludeAmbiguous: g.noAmbiguous,
+	})
+}
+
+// cmdAdd adds one credential, creating the vault if necessary.
+func cmdAdd(args []string) error {
+	const use = "usage: add [-password pass | -generate [generator flags]] [meta flags] site user"
+	var g genFlags
+	var m metaFlags
+	fs := flag.NewFlagSet("add", flag.ContinueOnError)
+	pass := fs.String("password", "", "the password (read from stdin if omitted)")
+	gen := fs.Bool("generate", false, "generate a random password and print it")
+	g.register(fs)
+	m.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return usageError(use)
+	}
+	var meta vault.Meta
+	hasMeta, err := m.apply(fs, &meta)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() != 2 || (*gen && *pass != "") {
+		return usageError(use)
+	}
+	if *gen || g.words > 0 {
+		pw, err := g.generate()
+		if err != nil {
+			return err
+		}
+		*pass = pw
+	}
+	if err := openVault(true); err != nil {
+		return err
+	}
+	if *pass == "" {
+		pw, err := readNewPassword(os.Stderr, "Password for "+fs.Arg(1)+"@"+fs.Arg(0)+": ")
+		if err != nil {
+			return err
+		}
+		*pass = pw
+	}
+	if strings.ContainsAny(*pass, " \t") || *pass == "" {
+		return usageError("Password must be non-empty and contain no whitespace.")
+	}
+	if err := store.Add(fs.Arg(0), fs.Arg(1), *pass); err != nil {
+		return err
+	}
+	if hasMeta {
+		if err := store.SetMeta(fs.Arg(0), fs.Arg(1), meta); err != nil {
+			return err
+		}
+	}
+	dirty = true
+	if err := commit(); err != nil {
+		return err
+	}
+	if *gen || g.words > 0 {
+		fmt.Println(*pass)
+	}
+	return nil
+}
+
+// cmdRemove removes a site (when it has a single user) or one user.
+func cmdRemove(args []string) error {
+	if len(args) < 1 || len(args) > 2 {
+		return usageError("usage: remove site [user]")
+	}
+	if err := openVault(false); err != nil {
+		return err
+	}
+	// The arguments are used as given, so names with spaces work.
+	args = append(args, "")
+	if err := store.Remove(args[0], args[1]); err != nil {
+		return err
+	}
+	dirty = true
+	return commit()
+}
+
+// cmdUpdate changes a user's password and/or name in place. Without
+// -user, -password or -generate the new password is prompted for.
+func cmdUpdate(args []string) error {
+	const use = "usage: update [-user name] [-password pass | -generate [generator flags]] [meta flags] site user"
+	var g genFlags
+	var m metaFlags
+	fs := flag.NewFlagSet("update", flag.ContinueOnError)
+	newUser := fs.String("user", "", "rename the user to `name`")
+	pass := fs.String("password", "", "the new password")
+	gen := fs.Bool("generate", false, "generate a random new password and print it")
+	g.register(fs)
+	m.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return usageError(use)
+	}
+	generated := *gen || g.words > 0
+	if fs.NArg() != 2 || (generated && *pass != "") {
+		return usageError(use)
+	}
+	if generated {
+		pw, err := g.generate()
+		if err != nil {
+			return err
+		}
+		*pass = pw
+	}
+	if err := openVault(false); err != nil {
+		return err
+	}
+	site, user := fs.Arg(0), fs.Arg(1)
+	e, err := store.Get(site, user)
+	if err != nil {
+		return err
+	}
+	hasMeta, err := m.apply(fs, &e.Meta)
+	if err != nil {
+		return err
+	}
+	if *pass == "" && *newUser == "" && !hasMeta {
+		pw, err := readNewPassword(os.Stderr, "New password for "+user+"@"+site+": ")
+		if err != nil {
+			return err
+		}
+		*pass = pw
+	}
+	if strings.ContainsAny(*pass, " \t") || strings.ContainsAny(*newUser, " \t") {
+		return usageError("User names and passwords may not contain whitespace.")
+	}
+	if err := store.Update(site, user, *newUser, *pass); err != nil {
+		return err
+	}
+	if *newUser != "" {
+		user = *newUser
+	}
+	if hasMeta {
+		if err := store.SetMeta(site, user, e.Meta); err != nil {
+			return err
+		}
+	}
+	dirty = true
+	if err := commit(); err != nil {
+		return err
+	}
+	if generated {
+		fmt.Println(*pass)
+	}
+	return nil
+}
+
+// cmdHistory lists the password history of one entry.
+func cmdHistory(args []string) error {
+	fs := flag.NewFlagSet("history", flag.ContinueOnError)
+	show := fs.Bool("show", false, "show passwords in clear text")
+	if err := fs.Parse(args); err != nil || fs.NArg() != 2 {
+		return usageError("usage: history [-show] site user")
+	}
+	if err := openVaultRead(); err != nil {
+		return err
+	}
+	return printHistory(fs.Arg(0), fs.Arg(1), *show)
+}
+
+// cmdRollback restores an earlier password of one entry.
+func cmdRollback(args []string) error {
+	if len(args) < 2 || len(args) > 3 {
+		return usageError("usage: rollback site user [n]")
+	}
+	n := 1
+	if len(args) == 3 {
+		v, err := strconv.Atoi(args[2])
+		if err != nil {
+			return usageError("usage: rollback site user [n]")
+		}
+		n = v
+	}
+	if err := openVault(false); err != nil {
+		return err
+	}
+	if err := store.Rollback(args[0], args[1], n); err != nil {
+		return err
+	}
+	dirty = true
+	return commit()
+}
+
+// cmdShow prints the detail view of one entry.
+func cmdShow(args []string) error {
+	fs := flag.NewFlagSet("show", flag.ContinueOnError)
+	reveal := fs.Bool("reveal", false, "show the password and secret fields in clear text")
+	if err := fs.Parse(args); err != nil || fs.NArg() < 1 || fs.NArg() > 2 {
+		return usageError("usage: show [-reveal] site [user]")
+	}
+	if err := openVaultRead(); err != nil {
+		return err
+	}
+	return printDetails(fs.Arg(0), fs.Arg(1), *reveal)
+}
+
+// cmdSearch prints the entries matching a query, best match first. It
+// exits with the not-found status when nothing matches.
+func cmdSearch(args []string) error {
+	q, err := parseSearch(args)
+	if err != nil {
+		return err
+	}
+	if err := openVaultRead(); err != nil {
+		return err
+	}
+	results := store.Search(q)
+	if len(results) == 0 {
+		return noMatchErr
+	}
+	printResults(results)
+	return nil
+}
+
+// cmdGet prints the password for site and user. The user may be omitted
+// when the site has exactly one.
+func cmdGet(args []string) error {
+	if len(args) < 1 || len(args) > 2 {
+		return usageError("usage: get site [user]")
+	}
+	if err := openVaultRead(); err != nil {
+		return err
+	}
+	args = append(args, "")
+	e, err := store.Get(args[0], args[1])
+	if err != nil {
+		return err
+	}
+	fmt.Println(e.Password)
+	return nil
+}
+
+// cmdCopy copies one password to the clipboard and, unless the timeout
+// is zero, stays running until it has been cleared again.
+func cmdCopy(args []string) error {
+	if len(args) < 1 || len(args) > 2 {
+		return usageError("usage: copy site [user]")
+	}
+	if err := openVaultRead(); err != nil {
+		return err
+	}
+	args = append(args, "")
+	if err := copyEntry(args[0], args[1]); err != nil {
+		return err
+	}
+	if clipTimeout > 0 {
+		time.Sleep(clipTimeout)
+		return clip.Flush()
+	}
+	return nil
+}
+
+// cmdImport merges a plaintext "site user pass" file or another password
+// manager's CSV export into the vault. Files ending in .csv are read as
+// CSV unless -format says otherwise.
+func cmdImport(args []string) error {
+	const use = "usage: import [-format legacy|csv] [-map site=col,user=col,password=col,...] [-on-duplicate skip|overwrite|rename] file"
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	format := fs.String("format", "", "input format: legacy or csv (default from the file name)")
+	mapping := fs.String("map", "", "CSV column mapping, e.g. site=Title,user=Login,password=Pass")
+	onDup := fs.String("on-duplicate", "skip", "for existing site and user: skip, overwrite or rename")
+	if err := fs.Parse(args); err != nil || fs.NArg() != 1 {
+		return usageError(use)
+	}
+	path := fs.Arg(0)
+	if *format == "" {
+		*format = "legacy"
+		if strings.EqualFold(filepath.Ext(path), ".csv") || *mapping != "" {
+			*format = "csv"
+		}
+	}
+	policy, err := vault.ParseDupPolicy(*onDup)
+	if err != nil {
+		return usageError(use)
+	}
+	var opts vault.CSVOptions
+	switch *format {
+	case "csv":
+		opts.OnDuplicate = policy
+		if *mapping != "" {
+			cols, err := vault.ParseColumnMap(*mapping)
+			if err != nil {
+				return usageError(strings.TrimPrefix(err.Error(), "vault: "))
+			}
+			opts.Columns = &cols
+		}
+	case "legacy":
+		if *mapping != "" || policy != vault.DupSkip {
+			return usageError("-map and -on-duplicate apply only to CSV imports.")
+		}
+	default:
+		return usageError(use)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := openVault(true); err != nil {
+		return err
+	}
+	var rep vault.ImportReport
+	if *format == "csv" {
+		var layout string
+		rep, layout, err = store.ImportCSV(f, opts)
+		if err == nil {
+			fmt.Fprintf(os.Stderr, "Reading %s CSV layout.\n", layout)
+		}
+	} else {
+		rep, err = store.ImportLegacy(f)
+	}
+	if err != nil {
+		return err
+	}
+	if rep.Added > 0 || len(rep.Overwritten) > 0 {
+		dirty = true
+	}
+	printImportReport(os.Stderr, rep)
+	return commit()
+}
+
+// cmdExport writes the vault to stdout or a file. Plaintext exports must
+// be confirmed by typing "yes"; with -encrypt a vault file sealed under a
+// separate export password is written instead.
+func cmdExport(args []string) error {
+	const use = "usage: export [-format legacy|csv|json | -encrypt] [-o file]"
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	out := fs.String("o", "", "write to `file` instead of stdout")
+	format := fs.String("format", "legacy", "plaintext format: legacy, csv or json")
+	encrypt := fs.Bool("encrypt", false, "write an encrypted vault protected by a new export password")
+	if err := fs.Parse(args); err != nil || fs.NArg() != 0 || !slices.Contains(vault.ExportFormats, *format) {
+		return usageError(use)
+	}
+	if *encrypt && *out == "" {
+		return usageError("An encrypted export needs -o file.")
+	}
+	if err := openVaultRead(); err != nil {
+		return err
+	}
+
+	if *encrypt {
+		pw, err := readNewPassword(os.Stderr, "Export password: ")
+		if err != nil {
+			return err
+		}
+		if pw == "" {
+			return usageError("Export password must not be empty.")
+		}
+		data, err := store.Seal(pw, vault.DefaultKDF)
+		if err != nil {
+			return err
+		}
+		return vault.WriteFileAtomic(*out, data, 0600)
+	}
+
+	dest := "standard output"
+	if *out != "" {
+		dest = *out
+	}
+	fmt.Fprintf(os.Stderr, "**Warning: This writes %d passwords UNENCRYPTED to %s.\n", store.Len(), dest)
+	fmt.Fprint(os.Stderr, "Type \"yes\" to continue: ")
+	if ans, err := readLine(); err != nil || strings.TrimSpace(ans) != "yes" {
+		return exportCancelledErr
+	}
+	var buf bytes.Buffer
+	if err := store.Export(&buf, *format); err != nil {
+		return err
+	}
+	if *out == "" {
+		_, err := os.Stdout.Write(buf.Bytes())
+		return err
+	}
+	return vault.WriteFileAtomic(*out, buf.Bytes(), 0600)
+}
+
+// cmdAudit reports weak, reused and old passwords.
+func cmdAudit(args []string) error {
+	const use = "usage: audit [-min-bits n] [-max-age days] [-format text|json]"
+	o := audit.DefaultOptions
+	fs := flag.NewFlagSet("audit", flag.ContinueOnError)
+	fs.Float64Var(&o.MinEntropy, "min-bits", o.MinEntropy, "flag passwords estimated below `n` bits")
+	maxAge := fs.Int("max-age", int(o.MaxAge/(24*time.Hour)), "flag passwords unchanged for more than `days` (0 to skip)")
+	format := fs.String("format", "text", "output format: text or json")
+	if err := fs.Parse(args); err != nil || fs.NArg() != 0 || *maxAge < 0 {
+		return usageError(use)
+	}
+	o.MaxAge = time.Duration(*maxAge) * 24 * time.Hour
+	if err := openVaultRead(); err != nil {
+		return err
+	}
+	rep := audit.Run(store.List(), o)
+	switch *format {
+	case "text":
+		return writeAuditText(os.Stdout, rep, o)
+	case "json":
+		return writeAuditJSON(os.Stdout, rep, o)
+	}
+	return usageError(use)
+}
+
+// cmdPasswd re-encrypts the vault under a new master password. The scrypt
+// cost is kept unless -logn, -r or -p raise (or lower) it.
+func cmdPasswd(args []string) error {
+	const use = "usage: passwd [-logn n] [-r n] [-p n]"
+	fs := flag.NewFlagSet("passwd", flag.ContinueOnError)
+	logN := fs.Uint("logn", 0, "scrypt cost, N = 2^`n` (default: keep the current cost)")
+	r := fs.Uint("r", 0, "scrypt block size (default: keep the current value)")
+	p := fs.Uint("p", 0, "scrypt parallelism (default: keep the current value)")
+	if err := fs.Parse(args); err != nil || fs.NArg() != 0 || *logN > 30 {
+		return usageError(use)
+	}
+	// Opening the vault checks the current master password.
+	if err := openVault(false); err != nil {
+		return err
+	}
+	kp := store.KDF()
+	if *logN > 0 {
+		kp.LogN = uint8(*logN)
+	}
+	if *r > 0 {
+		kp.R = uint32(*r)
+	}
+	if *p > 0 {
+		kp.P = uint32(*p)
+	}
+	if *r > math.MaxUint32 || *p > math.MaxUint32 || kp.Check() != nil {
+		return kdfRangeErr
+	}
+	pw, err := readNewPassword(os.Stderr, "New master password: ")
+	if err != nil {
+		return err
+	}
+	if pw == "" {
+		return emptyMasterErr
+	}
+	if err := store.Rekey(vaultPath, pw, kp); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Master password changed (%s).\n", kdfString(kp))
+	if weakKDF(kp) {
+		fmt.Fprintf(os.Stderr, "**Warning: This is below the default cost; use -logn %d -r %d to upgrade.\n", vault.DefaultKDF.LogN, vault.DefaultKDF.R)
+	}
+	return nil
+}
+
+// cmdShell runs the interactive menu.
+func cmdShell(args []string) error {
+	if len(args) != 0 {
+		return usageError("usage: shell")
+	}
+	if err := shell(stdin); err != nil {
+		// shell has already reported the error.
+		return errReported
+	}
+	return nil
+}
+
+// cmdGenerate prints a random password or passphrase without storing it.
+func cmdGenerate(args []string) error {
+	var g genFlags
+	fs := flag.NewFlagSet("generate", flag.ContinueOnError)
+	g.register(fs)
+	if err := fs.Parse(args); err != nil || fs.NArg() != 0 {
+		return usageError("usage: generate [generator flags]")
+	}
+	pw, err := g.generate()
+	if err != nil {
+		return err
+	}
+	fmt.Println(pw)
+	return nil
+}
@@ -0,0 +1,156 @@
+// details.go
+// Entry metadata: the detail view and the flags that edit it
+// Zarak Khan
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/ZarakL/Go-Projects/vault"
+)
+
+var fieldFormatErr = errors.New("**Error: Custom fields must be given as name=value.")
+
+// printDetails shows every stored attribute of one entry. The password and
+// secret fields are masked unless reveal is set.
+func printDetails(site, user string, reveal bool) error {
+	e, err := store.Get(site, user)
+	if err != nil {
+		return err
+	}
+	mask := func(v string, secret bool) string {
+		if secret && !reveal {
+			return passwordMask
+		}
+		return v
+	}
+
+	fmt.Printf("Website: \t %s\n", e.Site)
+	fmt.Printf("User:    \t %s\n", e.User)
+	fmt.Printf("Password:\t %s\n", mask(e.Password, true))
+	if e.URL != "" {
+		fmt.Printf("URL:     \t %s\n", e.URL)
+	}
+	if len(e.Tags) > 0 {
+		fmt.Printf("Tags:    \t %s\n", strings.Join(e.Tags, ", "))
+	}
+	fmt.Printf("Created: \t %s\n", formatTime(e.Created))
+	fmt.Printf("Modified:\t %s\n", formatTime(e.Modified))
+	if len(e.History) > 0 {
+		fmt.Printf("History: \t %d previous password(s)\n", len(e.History))
+	}
+	if e.TOTP != "" {
+		fmt.Printf("TOTP:    \t %s\n", mask(e.TOTP, true))
+	}
+	if e.Notes != "" {
+		fmt.Println("Notes:")
+		for _, line := range strings.Split(e.Notes, "\n") {
+			fmt.Printf("\t %s\n", line)
+		}
+	}
+	if len(e.Fields) > 0 {
+		fmt.Println("Fields:")
+		for _, f := range e.Fields {
+			fmt.Printf("\t %s \t %s\n", f.Name, mask(f.Value, f.Secret))
+		}
+	}
+	if reveal {
+		noteReveal(vault.EntrySlice{e})
+	}
+	return nil
+}
+
+// parseTags splits a comma-separated tag list, dropping blanks.
+func parseTags(s string) []string {
+	var tags []string
+	for _, t := range strings.Split(s, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			tags = append(tags, t)
+		}
+	}
+	return tags
+}
+
+// parseField splits a name=value custom field.
+func parseField(s string, secret bool) (vault.Field, error) {
+	name, value, ok := strings.Cut(s, "=")
+	name = strings.TrimSpace(name)
+	if !ok || name == "" {
+		return vault.Field{}, fieldFormatErr
+	}
+	return vault.Field{Name: name, Value: value, Secret: secret}, nil
+}
+
+// listFlag is a flag that may be repeated, collecting every value.
+type listFlag []string
+
+func (l *listFlag) String() string { return strings.Join(*l, ",") }
+
+func (l *listFlag) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+// metaFlags are the entry metadata flags shared by add and update.
+type metaFlags struct {
+	url, notes, tags, totp             string
+	fields, secretFields, deleteFields listFlag
+}
+
+// register adds the metadata flags to fs.
+func (m *metaFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&m.url, "url", "", "login `URL`")
+	fs.StringVar(&m.notes, "notes", "", "free-form notes")
+	fs.StringVar(&m.tags, "tags", "", "comma-separated `tags` (replaces existing tags)")
+	fs.Var(&m.fields, "field", "custom field `name=value` (repeatable)")
+	fs.Var(&m.secretFields, "secret-field", "secret custom field `name=value` (repeatable)")
+	fs.Var(&m.deleteFields, "delete-field", "remove the custom field `name` (repeatable)")
+	fs.StringVar(&m.totp, "totp", "", "two-factor `secret`: otpauth:// URI or base32 (\"\" to remove)")
+}
+
+// apply copies the metadata flags that were set on fs into meta and
+// reports whether any were.
+func (m *metaFlags) apply(fs *flag.FlagSet, meta *vault.Meta) (bool, error) {
+	changed := false
+	var err error
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "url":
+			meta.URL, changed = m.url, true
+		case "notes":
+			meta.Notes, changed = m.notes, true
+		case "tags":
+			meta.Tags, changed = parseTags(m.tags), true
+		case "totp":
+			uri, terr := parseTOTP(m.totp)
+			if terr != nil {
+				err = terr
+				return
+			}
+			meta.TOTP, changed = uri, true
+		}
+	})
+	for _, list := range []struct {
+		values listFlag
+		secret bool
+	}{{m.fields, false}, {m.secretFields, true}} {
+		for _, v := range list.values {
+			f, ferr := parseField(v, list.secret)
+			if ferr != nil {
+				err = ferr
+				continue
+			}
+			meta.SetField(f)
+			changed = true
+		}
+	}
+	for _, name := range m.deleteFields {
+		meta.DeleteField(name)
+		changed = true
+	}
+	return changed, err
+}
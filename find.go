@@ -0,0 +1,52 @@
+// find.go
+// Searching stored credentials from the shell and the command line
+// Zarak Khan
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ZarakL/Go-Projects/vault"
+)
+
+// parseSearch reads the search flags (-glob, -fuzzy, -tag) and query text
+// from args.
+func parseSearch(args []string) (vault.Query, error) {
+	const use = "usage: search [-glob | -fuzzy] [-tag tag] [text]"
+	fs := flag.NewFlagSet("search", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	glob := fs.Bool("glob", false, "match shell-style patterns such as *mail*")
+	fuzzy := fs.Bool("fuzzy", false, "match characters in order with gaps allowed")
+	tag := fs.String("tag", "", "only entries with this `tag`")
+	if err := fs.Parse(args); err != nil || (*glob && *fuzzy) {
+		return vault.Query{}, usageError(use)
+	}
+
+	q := vault.Query{Text: strings.Join(fs.Args(), " "), Tag: *tag}
+	switch {
+	case *glob:
+		q.Mode = vault.Glob
+	case *fuzzy:
+		q.Mode = vault.Fuzzy
+	}
+	return q, nil
+}
+
+// printResults lists search results best first, without passwords.
+func printResults(results []vault.Result) {
+	if len(results) == 0 {
+		fmt.Println("No matching entries.")
+		return
+	}
+	for _, r := range results {
+		line := fmt.Sprintf("Website: %s \t User: %s", r.Site, r.User)
+		if len(r.Tags) > 0 {
+			line += " \t Tags: " + strings.Join(r.Tags, ", ")
+		}
+		fmt.Println(line)
+	}
+}
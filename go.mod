@@ -0,0 +1,3 @@
+module github.com/ZarakL/Go-Projects
+
+go 1.24
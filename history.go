@@ -0,0 +1,53 @@
+// history.go
+// Viewing and rolling back an entry's previous passwords
+// Zarak Khan
+
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ZarakL/Go-Projects/vault"
+)
+
+// historyTimeFormat is used for password change timestamps.
+const historyTimeFormat = "2006-01-02 15:04"
+
+// printHistory lists the current and previous passwords of one entry,
+// newest first and numbered as Rollback expects. Passwords are masked
+// unless show is set.
+func printHistory(site, user string, show bool) error {
+	e, err := store.Get(site, user)
+	if err != nil {
+		return err
+	}
+	mask := func(pw string) string {
+		if show {
+			return pw
+		}
+		return passwordMask
+	}
+
+	fmt.Printf("Website: %s \t User: %s\n", e.Site, e.User)
+	fmt.Printf("\t current \t %s \t %s\n", formatTime(e.PasswordChanged()), mask(e.Password))
+	for n := 1; n <= len(e.History); n++ {
+		h := e.History[len(e.History)-n]
+		fmt.Printf("\t %7d \t %s \t %s\n", n, formatTime(h.Replaced), mask(h.Password))
+	}
+	if len(e.History) == 0 {
+		fmt.Println("\t (no previous passwords)")
+	}
+	if show {
+		noteReveal(vault.EntrySlice{e})
+	}
+	return nil
+}
+
+// formatTime formats a timestamp for display, or "-" if it is unset.
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format(historyTimeFormat)
+}
@@ -0,0 +1,166 @@
+// listing.go
+// Listing output formats: tabbed text, aligned table, JSON and CSV
+// Zarak Khan
+
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ZarakL/Go-Projects/vault"
+)
+
+// listOptions control how a listing is ordered and printed.
+type listOptions struct {
+	show   bool
+	sort   vault.SortKey
+	format string // text, table, json or csv
+
+	breaches map[entryKey]int // breach list hits; nil if no list is configured
+}
+
+// parseListFlags reads -show, -sort and -format from args and returns the
+// remaining arguments.
+func parseListFlags(args []string) (listOptions, []string, error) {
+	const use = "usage: list [-show] [-sort site|user|modified] [-format text|table|json|csv] [site]"
+	var o listOptions
+	fs := flag.NewFlagSet("list", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.BoolVar(&o.show, "show", false, "show passwords in clear text")
+	sortKey := fs.String("sort", "site", "order by site, user or modified")
+	fs.StringVar(&o.format, "format", "text", "output format: text, table, json or csv")
+	if err := fs.Parse(args); err != nil {
+		return o, nil, usageError(use)
+	}
+	key, err := vault.ParseSortKey(*sortKey)
+	if err != nil {
+		return o, nil, usageError(use)
+	}
+	o.sort = key
+	switch o.format {
+	case "text", "table", "json", "csv":
+	default:
+		return o, nil, usageError(use)
+	}
+	return o, fs.Args(), nil
+}
+
+// printListing sorts entries and writes them to stdout in the selected
+// format. Passwords are masked (text, table) or omitted (json, csv) unless
+// o.show is set, in which case every entry is noted as revealed. With a
+// breach list configured, passwords found in it are marked.
+func printListing(entries vault.EntrySlice, o listOptions) error {
+	entries.Sort(o.sort)
+	breaches, err := breachHits(entries)
+	if err != nil {
+		return err
+	}
+	o.breaches = breaches
+	switch o.format {
+	case "table":
+		err = writeTable(os.Stdout, entries, o)
+	case "json":
+		err = writeJSON(os.Stdout, entries, o)
+	case "csv":
+		err = writeCSV(os.Stdout, entries, o)
+	default:
+		printEntries(entries, o)
+	}
+	if err == nil && o.show {
+		noteReveal(entries)
+	}
+	return err
+}
+
+// modifiedTime formats a modification time for machine-readable output.
+func modifiedTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// writeTable prints entries as aligned columns with a header row.
+func writeTable(w io.Writer, entries vault.EntrySlice, o listOptions) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	header := "SITE\tUSER\tPASSWORD\tMODIFIED\tTAGS"
+	if o.breaches != nil {
+		header += "\tBREACHED"
+	}
+	fmt.Fprintln(tw, header)
+	for _, e := range entries {
+		pw := passwordMask
+		if o.show {
+			pw = e.Password
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s", e.Site, e.User, pw, formatTime(e.Modified), strings.Join(e.Tags, ","))
+		if n, ok := o.breaches[entryKey{e.Site, e.User}]; ok {
+			fmt.Fprintf(tw, "\t%d times", n)
+		}
+		fmt.Fprintln(tw)
+	}
+	return tw.Flush()
+}
+
+// listRecord is one entry in JSON output.
+type listRecord struct {
+	Site     string   `json:"site"`
+	User     string   `json:"user"`
+	Password string   `json:"password,omitempty"`
+	URL      string   `json:"url,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+	Modified string   `json:"modified,omitempty"`
+	Breached int      `json:"breached,omitempty"`
+}
+
+// writeJSON prints entries as an indented JSON array.
+func writeJSON(w io.Writer, entries vault.EntrySlice, o listOptions) error {
+	records := make([]listRecord, 0, len(entries))
+	for _, e := range entries {
+		r := listRecord{Site: e.Site, User: e.User, URL: e.URL, Tags: e.Tags, Modified: modifiedTime(e.Modified)}
+		r.Breached = o.breaches[entryKey{e.Site, e.User}]
+		if o.show {
+			r.Password = e.Password
+		}
+		records = append(records, r)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(records)
+}
+
+// writeCSV prints entries as CSV with a header row. The password column
+// is only present when o.show is set, and the breached column only with a
+// breach list.
+func writeCSV(w io.Writer, entries vault.EntrySlice, o listOptions) error {
+	cw := csv.NewWriter(w)
+	header := []string{"site", "user", "url", "tags", "modified"}
+	if o.show {
+		header = []string{"site", "user", "password", "url", "tags", "modified"}
+	}
+	if o.breaches != nil {
+		header = append(header, "breached")
+	}
+	cw.Write(header)
+	for _, e := range entries {
+		row := []string{e.Site, e.User, e.URL, strings.Join(e.Tags, ","), modifiedTime(e.Modified)}
+		if o.show {
+			row = append(row[:2], append([]string{e.Password}, row[2:]...)...)
+		}
+		if o.breaches != nil {
+			row = append(row, strconv.Itoa(o.breaches[entryKey{e.Site, e.User}]))
+		}
+		cw.Write(row)
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,80 @@
+// masterpw.go
+// Changing the master password and the key derivation cost
+// Zarak Khan
+
+package main
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ZarakL/Go-Projects/vault"
+)
+
+var (
+	wrongMasterErr = errors.New("**Error: Incorrect master password.")
+	emptyMasterErr = errors.New("**Error: The master password must not be empty.")
+	kdfRangeErr    = usageError("The scrypt cost is out of range: 128*N*r may be at most 1 GiB and p at most 16.")
+)
+
+// kdfString describes scrypt parameters for messages.
+func kdfString(kp vault.KDFParams) string {
+	return fmt.Sprintf("scrypt N=2^%d r=%d p=%d", kp.LogN, kp.R, kp.P)
+}
+
+// weakKDF reports whether kp costs less than vault.DefaultKDF.
+func weakKDF(kp vault.KDFParams) bool {
+	d := vault.DefaultKDF
+	return kp.LogN < d.LogN || kp.R < d.R
+}
+
+// changeMasterPassword handles the P command: check the current master
+// password, then re-encrypt the vault under a new one, offering to raise
+// an outdated key derivation cost at the same time. Unsaved changes are
+// written along with it.
+func changeMasterPassword(reader *bufio.Reader) {
+	if vaultPath == "" {
+		fmt.Println("**Error: The map has no vault file yet. Save it with S first.")
+		return
+	}
+	cur, err := readPassword(os.Stdout, "Enter the current master password: ")
+	if err != nil {
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(cur), []byte(masterPassword)) != 1 {
+		fmt.Println(wrongMasterErr)
+		return
+	}
+	pw, err := readNewPassword(os.Stdout, "Enter the new master password: ")
+	if err == nil && pw == "" {
+		err = emptyMasterErr
+	}
+	if err != nil {
+		fmt.Println(errorText(err))
+		return
+	}
+
+	kp := store.KDF()
+	if err := kp.Check(); err != nil {
+		fmt.Println(errorText(kdfRangeErr))
+		return
+	}
+	if weakKDF(kp) {
+		fmt.Printf("The vault uses %s, below the current default of %s.\n", kdfString(kp), kdfString(vault.DefaultKDF))
+		fmt.Print("Upgrade it? (Y/N): ")
+		ans, _ := reader.ReadString('\n')
+		if strings.ToUpper(strings.TrimSpace(ans)) == "Y" {
+			kp = vault.DefaultKDF
+		}
+	}
+	if err := store.Rekey(vaultPath, pw, kp); err != nil {
+		fmt.Println("**Error writing vault file:", err)
+		return
+	}
+	masterPassword, dirty = pw, false
+	fmt.Printf("Master password changed and vault saved (%s).\n", kdfString(kp))
+}
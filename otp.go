@@ -0,0 +1,80 @@
+// otp.go
+// Two-factor codes for entries that hold a TOTP secret
+// Zarak Khan
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/ZarakL/Go-Projects/totp"
+)
+
+var noTOTPErr = errors.New("**Error: This entry has no two-factor secret.")
+
+// totpClock supplies the time codes are computed for. It is a variable so
+// codes can be checked against a fixed clock.
+var totpClock = time.Now
+
+// parseTOTP accepts an otpauth:// URI or a base32 secret and returns the
+// URI stored on the entry. An empty secret removes it.
+func parseTOTP(secret string) (string, error) {
+	if secret == "" {
+		return "", nil
+	}
+	k, err := totp.Parse(secret)
+	if err != nil {
+		return "", err
+	}
+	return k.URI(), nil
+}
+
+// currentCode returns the code for site and user at totpClock() and how
+// long it remains valid.
+func currentCode(site, user string) (string, time.Duration, error) {
+	e, err := store.Get(site, user)
+	if err != nil {
+		return "", 0, err
+	}
+	if e.TOTP == "" {
+		return "", 0, noTOTPErr
+	}
+	k, err := totp.ParseURI(e.TOTP)
+	if err != nil {
+		return "", 0, err
+	}
+	code, left := k.Code(totpClock())
+	return code, left, nil
+}
+
+// printTOTP handles the T menu command.
+func printTOTP(site, user string) error {
+	code, left, err := currentCode(site, user)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Code: %s (valid for %s)\n", code, left)
+	return nil
+}
+
+// cmdTOTP prints the current code on stdout and its remaining validity on
+// stderr, so scripts can capture the code alone.
+func cmdTOTP(args []string) error {
+	if len(args) < 1 || len(args) > 2 {
+		return usageError("usage: totp site [user]")
+	}
+	if err := openVaultRead(); err != nil {
+		return err
+	}
+	args = append(args, "")
+	code, left, err := currentCode(args[0], args[1])
+	if err != nil {
+		return err
+	}
+	fmt.Println(code)
+	fmt.Fprintf(os.Stderr, "Valid for %s.\n", left)
+	return nil
+}
@@ -0,0 +1,44 @@
+// otp_test.go
+// Two-factor codes computed against a fixed clock
+// Zarak Khan
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ZarakL/Go-Projects/vault"
+)
+
+func TestCurrentCodeUsesClock(t *testing.T) {
+	defer func(s *vault.Store, c func() time.Time) { store, totpClock = s, c }(store, totpClock)
+	store = vault.New()
+	if err := store.Add("example.com", "alice", "pw"); err != nil {
+		t.Fatal(err)
+	}
+	// RFC 6238 SHA1 seed "12345678901234567890" in base32.
+	uri, err := parseTOTP("GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, _ := store.Get("example.com", "alice")
+	e.TOTP = uri
+	if err := store.SetMeta("example.com", "alice", e.Meta); err != nil {
+		t.Fatal(err)
+	}
+
+	totpClock = func() time.Time { return time.Unix(1111111109, 0) }
+	code, left, err := currentCode("example.com", "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The 6-digit code is the low digits of the RFC's 07081804.
+	if code != "081804" || left != 1*time.Second {
+		t.Errorf("got %s valid for %s, want 081804 valid for 1s", code, left)
+	}
+
+	if _, _, err := currentCode("example.com", "nobody"); err == nil {
+		t.Error("expected an error for a missing user")
+	}
+}
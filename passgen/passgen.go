@@ -0,0 +1,141 @@
+// passgen.go
+// Random password and passphrase generation
+// Zarak Khan
+//
+// Package passgen generates passwords from configurable character classes
+// and diceware-style passphrases from an embedded wordlist. All randomness
+// comes from crypto/rand.
+
+package passgen
+
+import (
+	"crypto/rand"
+	_ "embed"
+	"errors"
+	"math/big"
+	"strings"
+)
+
+// Character classes. Symbols avoid whitespace and quotes so generated
+// passwords survive the plaintext "site user pass" format.
+const (
+	Lower   = "abcdefghijklmnopqrstuvwxyz"
+	Upper   = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	Digits  = "0123456789"
+	Symbols = "!#$%&()*+,-./:;<=>?@[]^_{|}~"
+
+	// Ambiguous characters are easily confused when read or retyped.
+	Ambiguous = "Il1|O0o"
+)
+
+//go:embed wordlist.txt
+var wordlistText string
+
+// wordlist is the embedded passphrase dictionary, one word per line.
+var wordlist = strings.Fields(wordlistText)
+
+// Errors returned by the generators.
+var (
+	ErrNoClasses = errors.New("passgen: no character classes selected")
+	ErrLength    = errors.New("passgen: length too short for the selected classes")
+	ErrWords     = errors.New("passgen: passphrase needs at least one word")
+)
+
+// Options selects the length and character classes of a generated
+// password.
+type Options struct {
+	Length                        int
+	Lower, Upper, Digits, Symbols bool
+	ExcludeAmbiguous              bool
+}
+
+// DefaultOptions is a 20-character password drawn from every class.
+var DefaultOptions = Options{Length: 20, Lower: true, Upper: true, Digits: true, Symbols: true}
+
+// classes returns the enabled character sets, with ambiguous characters
+// removed if requested.
+func (o Options) classes() []string {
+	var out []string
+	add := func(on bool, set string) {
+		if !on {
+			return
+		}
+		if o.ExcludeAmbiguous {
+			set = strings.Map(func(r rune) rune {
+				if strings.ContainsRune(Ambiguous, r) {
+					return -1
+				}
+				return r
+			}, set)
+		}
+		out = append(out, set)
+	}
+	add(o.Lower, Lower)
+	add(o.Upper, Upper)
+	add(o.Digits, Digits)
+	add(o.Symbols, Symbols)
+	return out
+}
+
+// randInt returns a uniform random integer in [0, n).
+func randInt(n int) (int, error) {
+	v, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		return 0, err
+	}
+	return int(v.Int64()), nil
+}
+
+// Password returns a random password containing at least one character
+// from every enabled class.
+func Password(o Options) (string, error) {
+	classes := o.classes()
+	if len(classes) == 0 {
+		return "", ErrNoClasses
+	}
+	if o.Length < len(classes) {
+		return "", ErrLength
+	}
+
+	// One character from each class, the rest from the combined pool.
+	all := strings.Join(classes, "")
+	out := make([]byte, o.Length)
+	for i := range out {
+		set := all
+		if i < len(classes) {
+			set = classes[i]
+		}
+		j, err := randInt(len(set))
+		if err != nil {
+			return "", err
+		}
+		out[i] = set[j]
+	}
+
+	// Fisher-Yates shuffle so the guaranteed characters are not always
+	// at the front.
+	for i := len(out) - 1; i > 0; i-- {
+		j, err := randInt(i + 1)
+		if err != nil {
+			return "", err
+		}
+		out[i], out[j] = out[j], out[i]
+	}
+	return string(out), nil
+}
+
+// Passphrase returns words random wordlist entries joined by sep.
+func Passphrase(words int, sep string) (string, error) {
+	if words < 1 {
+		return "", ErrWords
+	}
+	out := make([]string, words)
+	for i := range out {
+		j, err := randInt(len(wordlist))
+		if err != nil {
+			return "", err
+		}
+		out[i] = wordlist[j]
+	}
+	return strings.Join(out, sep), nil
+}
@@ -0,0 +1,53 @@
+// peercred_linux.go
+// Unix socket peer credentials and process detaching for the agent (Linux)
+// Zarak Khan
+
+//go:build linux
+
+package main
+
+import (
+	"net"
+	"os"
+	"syscall"
+)
+
+// agentSupported reports whether the agent can check peer credentials
+// here.
+const agentSupported = true
+
+// peerUID returns the user ID of the process at the other end of a Unix
+// socket connection, using SO_PEERCRED.
+func peerUID(c *net.UnixConn) (int, error) {
+	raw, err := c.SyscallConn()
+	if err != nil {
+		return 0, err
+	}
+	var cred *syscall.Ucred
+	var credErr error
+	err = raw.Control(func(fd uintptr) {
+		cred, credErr = syscall.GetsockoptUcred(int(fd), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	})
+	if err != nil {
+		return 0, err
+	}
+	if credErr != nil {
+		return 0, credErr
+	}
+	return int(cred.Uid), nil
+}
+
+// fileUID returns the owner of the file described by fi.
+func fileUID(fi os.FileInfo) (int, bool) {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, false
+	}
+	return int(st.Uid), true
+}
+
+// detachedAttr starts the background agent in its own session, so it
+// outlives the terminal that launched it.
+func detachedAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{Setsid: true}
+}
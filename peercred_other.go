@@ -0,0 +1,34 @@
+// peercred_other.go
+// Fallback for platforms without Unix socket peer credentials
+// Zarak Khan
+
+//go:build !linux
+
+package main
+
+import (
+	"errors"
+	"net"
+	"os"
+	"syscall"
+)
+
+// agentSupported is false: without peer credentials the agent would
+// refuse every connection.
+const agentSupported = false
+
+// peerUID is not supported on this platform, so the agent refuses every
+// connection.
+func peerUID(c *net.UnixConn) (int, error) {
+	return 0, errors.New("peer credentials are not supported on this platform")
+}
+
+// fileUID is not supported on this platform; no file is trusted.
+func fileUID(fi os.FileInfo) (int, bool) {
+	return 0, false
+}
+
+// detachedAttr returns nil; the agent is started as a plain child process.
+func detachedAttr() *syscall.SysProcAttr {
+	return nil
+}
@@ -0,0 +1,75 @@
+// prompt.go
+// Password prompts that keep secrets off the screen
+// Zarak Khan
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+)
+
+var mismatchErr = errors.New("**Error: Passwords do not match.")
+
+// stdinIsTerminal reports whether prompts are being answered interactively.
+func stdinIsTerminal() bool {
+	return isTerminal(int(os.Stdin.Fd()))
+}
+
+// readPassword writes prompt to w and reads one line from stdin. On a
+// terminal echo is disabled while typing; for pipes and files the line is
+// read as-is.
+func readPassword(w io.Writer, prompt string) (string, error) {
+	if !stdinIsTerminal() {
+		fmt.Fprint(w, prompt)
+		return readLine()
+	}
+
+	restore, err := disableEcho(int(os.Stdin.Fd()))
+	if err != nil {
+		fmt.Fprintf(w, "**Warning: The password will be visible as you type (%v).\n", err)
+		fmt.Fprint(w, prompt)
+		return readLine()
+	}
+	fmt.Fprint(w, prompt)
+	// Put the terminal back if the user interrupts at the prompt.
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt)
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-sig:
+			restore()
+			fmt.Fprintln(w)
+			os.Exit(130)
+		case <-done:
+		}
+	}()
+
+	line, err := readLine()
+	close(done)
+	signal.Stop(sig)
+	restore()
+	fmt.Fprintln(w) // the user's Enter was not echoed
+	return line, err
+}
+
+// readNewPassword reads a password that is being set. On a terminal it is
+// entered twice and must match; scripted input supplies it once.
+func readNewPassword(w io.Writer, prompt string) (string, error) {
+	pw, err := readPassword(w, prompt)
+	if err != nil || !stdinIsTerminal() {
+		return pw, err
+	}
+	again, err := readPassword(w, "Confirm password: ")
+	if err != nil {
+		return "", err
+	}
+	if pw != again {
+		return "", mismatchErr
+	}
+	return pw, nil
+}
@@ -0,0 +1,58 @@
+// reveal.go
+// Masked listing and an audit of passwords revealed this session
+// Zarak Khan
+
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ZarakL/Go-Projects/vault"
+)
+
+// passwordMask stands in for every hidden password. It has a fixed width
+// so the listing does not leak password lengths.
+const passwordMask = "********"
+
+// revealRecord notes one password shown in clear text.
+type revealRecord struct {
+	at         time.Time
+	site, user string
+}
+
+// revealed lists every password shown in clear text this session, oldest
+// first.
+var revealed []revealRecord
+
+// noteReveal records that the passwords of entries were shown.
+func noteReveal(entries vault.EntrySlice) {
+	now := time.Now()
+	for _, e := range entries {
+		revealed = append(revealed, revealRecord{at: now, site: e.Site, user: e.User})
+	}
+}
+
+// revealEntry prints the password for one site and user (the user may be
+// omitted when the site has only one) and records it in the audit.
+func revealEntry(site, user string) error {
+	e, err := store.Get(site, user)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("\t %s \t %s\n", e.User, e.Password)
+	noteReveal(vault.EntrySlice{e})
+	return nil
+}
+
+// printRevealLog shows which entries have been revealed this session.
+func printRevealLog() {
+	if len(revealed) == 0 {
+		fmt.Println("No passwords have been revealed this session.")
+		return
+	}
+	fmt.Println("Passwords revealed this session:")
+	for _, r := range revealed {
+		fmt.Printf("\t %s \t %s \t %s\n", r.at.Format(time.TimeOnly), r.site, r.user)
+	}
+}
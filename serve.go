@@ -0,0 +1,247 @@
+// serve.go
+// The serve command: the HTTP/JSON API on a loopback port or Unix socket
+// Zarak Khan
+
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/ZarakL/Go-Projects/api"
+	"github.com/ZarakL/Go-Projects/vault"
+)
+
+var (
+	notLoopbackErr = usageError("serve only listens on loopback addresses; use -socket for local access by path.")
+	fileChangedErr = errors.New("the vault file was changed by another command; retry")
+)
+
+// vaultFile tracks the vault file a server holds, so that changes made
+// by other commands are picked up rather than overwritten.
+type vaultFile struct {
+	mu   sync.Mutex  // held for each request, so a reload never splits one
+	file os.FileInfo // the file as last loaded or saved; nil if not yet saved
+	log  *log.Logger
+}
+
+// newVaultFile starts tracking the vault file as it is now. A new vault
+// need not exist yet.
+func newVaultFile(logger *log.Logger) (*vaultFile, error) {
+	fi, err := os.Stat(vaultPath)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return &vaultFile{file: fi, log: logger}, nil
+}
+
+// changed reports whether the vault file is no longer the one held.
+func (v *vaultFile) changed() bool {
+	fi, err := os.Stat(vaultPath)
+	if os.IsNotExist(err) && v.file == nil {
+		return false
+	}
+	if err != nil || v.file == nil {
+		return true
+	}
+	return !os.SameFile(fi, v.file) || !fi.ModTime().Equal(v.file.ModTime()) || fi.Size() != v.file.Size()
+}
+
+// reload replaces the store's entries with those in the vault file.
+func (v *vaultFile) reload() error {
+	fi, err := os.Stat(vaultPath)
+	if err != nil {
+		return err
+	}
+	s, err := vault.Open(vaultPath, masterPassword)
+	if err != nil {
+		return err
+	}
+	store.Replace(s)
+	v.file = fi
+	return nil
+}
+
+// save writes s to the vault file unless another command has changed the
+// file since it was loaded.
+func (v *vaultFile) save(s *vault.Store) error {
+	if v.changed() {
+		return fileChangedErr
+	}
+	if err := s.Save(vaultPath, masterPassword); err != nil {
+		return err
+	}
+	fi, err := os.Stat(vaultPath)
+	v.file = fi
+	return err
+}
+
+// guard wraps h so that each request first reloads the vault file if
+// another command changed it. If that fails, failed answers instead.
+func (v *vaultFile) guard(h http.Handler, failed func(w http.ResponseWriter)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		v.mu.Lock()
+		defer v.mu.Unlock()
+		if v.changed() {
+			if err := v.reload(); err != nil {
+				v.log.Printf("cannot reload %s: %v", vaultPath, err)
+				failed(w)
+				return
+			}
+			v.log.Printf("reloaded %s after it changed on disk", vaultPath)
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// apiReply sends a JSON error in the API's format.
+func apiReply(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": msg})
+}
+
+// apiToken returns the bearer token from file, $PM_API_TOKEN or, failing
+// both, a fresh random token that is printed once.
+func apiToken(file string) (string, error) {
+	if file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(string(data)), nil
+	}
+	if t := os.Getenv("PM_API_TOKEN"); t != "" {
+		return t, nil
+	}
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	t := hex.EncodeToString(b)
+	fmt.Fprintln(os.Stderr, "API token:", t)
+	return t, nil
+}
+
+// listen opens a Unix socket readable only by this user when socket is
+// set, and otherwise a TCP listener that must be on a loopback address.
+func listen(addr, socket string) (net.Listener, error) {
+	if socket != "" {
+		// Replace a socket left behind by an earlier run, but never any
+		// other kind of file.
+		if fi, err := os.Lstat(socket); err == nil && fi.Mode()&os.ModeSocket != 0 {
+			os.Remove(socket)
+		}
+		ln, err := net.Listen("unix", socket)
+		if err != nil {
+			return nil, err
+		}
+		if err := os.Chmod(socket, 0600); err != nil {
+			ln.Close()
+			return nil, err
+		}
+		return ln, nil
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, usageError("Bad -addr: " + err.Error())
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return nil, notLoopbackErr
+	}
+	return net.Listen("tcp", addr)
+}
+
+// serveUntilSignal runs srv on ln until SIGINT or SIGTERM, then shuts it
+// down gracefully.
+func serveUntilSignal(srv *http.Server, ln net.Listener) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdown)
+	}()
+	if err := srv.Serve(ln); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// cmdServe exposes the vault over the HTTP/JSON API (see package api),
+// saving it after every change, until interrupted. Changes other commands
+// make to the vault file are reloaded, never saved over.
+func cmdServe(args []string) error {
+	const use = "usage: serve [-addr host:port | -socket path] [-token-file file] [-audit-log file]"
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	addr := fs.String("addr", "127.0.0.1:7878", "loopback `address` to listen on")
+	socket := fs.String("socket", "", "listen on a Unix socket at `path` instead")
+	tokenFile := fs.String("token-file", "", "read the bearer token from `file` (default $PM_API_TOKEN or a random token)")
+	auditLog := fs.String("audit-log", "", "append the request log to `file` (default stderr)")
+	if err := fs.Parse(args); err != nil || fs.NArg() != 0 {
+		return usageError(use)
+	}
+
+	ln, err := listen(*addr, *socket)
+	if err != nil {
+		return err
+	}
+	defer ln.Close()
+	if *socket != "" {
+		defer os.Remove(*socket)
+	}
+	if err := openVault(true); err != nil {
+		return err
+	}
+	token, err := apiToken(*tokenFile)
+	if err != nil {
+		return err
+	}
+	if token == "" {
+		return usageError("The API token must not be empty.")
+	}
+	logOut := io.Writer(os.Stderr)
+	if *auditLog != "" {
+		f, err := os.OpenFile(*auditLog, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		logOut = f
+	}
+	logger := log.New(logOut, "", log.LstdFlags)
+	v, err := newVaultFile(logger)
+	if err != nil {
+		return err
+	}
+	handler := api.New(api.Config{
+		Store: store,
+		Token: token,
+		Log:   logger,
+		Save:  v.save,
+	})
+	srv := &http.Server{
+		Handler: v.guard(handler, func(w http.ResponseWriter) {
+			apiReply(w, http.StatusServiceUnavailable, "the vault file changed and could not be reloaded")
+		}),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	fmt.Fprintf(os.Stderr, "Serving %s on %s; interrupt to stop.\n", vaultPath, ln.Addr())
+	return serveUntilSignal(srv, ln)
+}
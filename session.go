@@ -0,0 +1,149 @@
+// session.go
+// Locking the interactive shell, by hand or after a period of inactivity
+// Zarak Khan
+//
+// While locked, the decrypted store is dropped from memory and only a
+// copy sealed under the master password is kept. Unlocking decrypts that
+// copy again, so unsaved changes survive a lock.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/ZarakL/Go-Projects/vault"
+)
+
+// lockAfter is how long the menu may sit idle before the session locks
+// itself (0 to never lock automatically).
+var lockAfter = 5 * time.Minute
+
+var noMasterErr = errors.New("**Error: Set a master password before locking.")
+
+// session guards the store against the idle timer. The shell holds mu
+// whenever it is not waiting at the menu prompt, so the timer can only
+// lock the session between commands.
+type session struct {
+	mu     sync.Mutex
+	timer  *time.Timer
+	sealed []byte // the store while locked, nil otherwise
+}
+
+// locked reports whether the store has been sealed away.
+func (s *session) locked() bool {
+	return s.sealed != nil
+}
+
+// wait releases the session while the shell waits for input, starting the
+// idle timer. The caller must hold s.mu.
+func (s *session) wait() {
+	if lockAfter > 0 && masterPassword != "" && !s.locked() {
+		s.timer = time.AfterFunc(lockAfter, s.autoLock)
+	}
+	s.mu.Unlock()
+}
+
+// resume reclaims the session once input has arrived. If the idle timer
+// has already fired, it waits for the lock to finish.
+func (s *session) resume() {
+	s.mu.Lock()
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+}
+
+// autoLock runs on the timer's goroutine.
+func (s *session) autoLock() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.locked() {
+		return
+	}
+	if err := s.lock(); err != nil {
+		fmt.Println()
+		fmt.Println(errorText(err))
+		return
+	}
+	fmt.Printf("\nSession locked after %s of inactivity. Press Enter to unlock.\n", lockAfter)
+}
+
+// lock seals the store under the master password, wipes the decrypted
+// entries and clears the clipboard. The caller must hold s.mu.
+func (s *session) lock() error {
+	if masterPassword == "" {
+		return noMasterErr
+	}
+	sealed, err := store.Seal(masterPassword, store.KDF())
+	if err != nil {
+		return err
+	}
+	s.sealed = sealed
+	store.Wipe()
+	store = vault.New()
+	masterPassword = ""
+	clip.Flush()
+	runtime.GC()
+	return nil
+}
+
+// unlock asks for the master password until it opens the sealed store.
+// It returns an error only if input ends first.
+func (s *session) unlock() error {
+	for {
+		pw, err := readPassword(os.Stdout, "Session locked. Enter the master password: ")
+		if err != nil {
+			return err
+		}
+		st, err := vault.Unseal(s.sealed, pw)
+		if err != nil {
+			fmt.Println(errorText(err))
+			continue
+		}
+		store, masterPassword, s.sealed = st, pw, nil
+		fmt.Println("Session unlocked.")
+		return nil
+	}
+}
+
+// exitLocked ends a shell whose input ran out while locked. The sealed
+// copy is already a complete vault, so unsaved changes can still be
+// written without the master password.
+func (s *session) exitLocked() {
+	fmt.Println()
+	if dirty && vaultPath != "" {
+		if err := vault.WriteFileAtomic(vaultPath, s.sealed, 0600); err != nil {
+			fmt.Println("**Error writing vault file:", err)
+		} else {
+			fmt.Println("Vault saved.")
+		}
+	}
+	fmt.Println("Exiting program.")
+}
+
+// lockSession handles the K command. A session started without a vault
+// has no master password yet, so one is chosen first; it is also used
+// when the map is later saved.
+func lockSession(s *session) {
+	if masterPassword == "" {
+		pw, err := readNewPassword(os.Stdout, "Choose a master password for this session: ")
+		if err == nil && pw == "" {
+			err = noMasterErr
+		}
+		if err != nil {
+			fmt.Println(errorText(err))
+			return
+		}
+		masterPassword = pw
+	}
+	if err := s.lock(); err != nil {
+		fmt.Println(errorText(err))
+		return
+	}
+	fmt.Println("Session locked.")
+}
@@ -0,0 +1,44 @@
+// term_bsd.go
+// Terminal echo control for hidden password input (macOS and the BSDs)
+// Zarak Khan
+
+//go:build darwin || freebsd || netbsd || openbsd
+
+package main
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// ioctlTermios gets or sets the terminal attributes of fd.
+func ioctlTermios(fd int, req uintptr, t *syscall.Termios) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), req, uintptr(unsafe.Pointer(t)))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// isTerminal reports whether fd refers to a terminal.
+func isTerminal(fd int) bool {
+	var t syscall.Termios
+	return ioctlTermios(fd, syscall.TIOCGETA, &t) == nil
+}
+
+// disableEcho turns off echo on the terminal fd and returns a function that
+// restores the previous settings.
+func disableEcho(fd int) (func(), error) {
+	var old syscall.Termios
+	if err := ioctlTermios(fd, syscall.TIOCGETA, &old); err != nil {
+		return nil, err
+	}
+	t := old
+	t.Lflag &^= syscall.ECHO
+	t.Lflag |= syscall.ICANON | syscall.ISIG
+	t.Iflag |= syscall.ICRNL
+	if err := ioctlTermios(fd, syscall.TIOCSETA, &t); err != nil {
+		return nil, err
+	}
+	return func() { ioctlTermios(fd, syscall.TIOCSETA, &old) }, nil
+}
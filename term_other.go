@@ -0,0 +1,31 @@
+// term_other.go
+// Fallback for platforms without terminal echo control
+// Zarak Khan
+
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+// isTerminal reports whether fd, one of the standard streams, is a
+// character device, which is as close as this platform gets to knowing it
+// is a terminal. Passwords typed there are then echoed with a warning but
+// still confirmed.
+func isTerminal(fd int) bool {
+	for _, f := range []*os.File{os.Stdin, os.Stdout, os.Stderr} {
+		if int(f.Fd()) == fd {
+			fi, err := f.Stat()
+			return err == nil && fi.Mode()&os.ModeCharDevice != 0
+		}
+	}
+	return false
+}
+
+// disableEcho is not supported on this platform.
+func disableEcho(fd int) (func(), error) {
+	return nil, errors.New("disabling terminal echo is not supported on this platform")
+}
@@ -0,0 +1,169 @@
+// totp.go
+// Time-based one-time passwords (RFC 6238)
+// Zarak Khan
+//
+// Package totp computes the rolling two-factor codes shown by
+// authenticator apps. Keys are read from the otpauth:// URIs those apps
+// use for QR codes, or from a bare base32 secret.
+
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Errors returned when parsing a key.
+var (
+	ErrURI       = errors.New("totp: not an otpauth://totp/ URI")
+	ErrSecret    = errors.New("totp: secret is not valid base32")
+	ErrAlgorithm = errors.New("totp: unsupported algorithm")
+	ErrDigits    = errors.New("totp: digits must be between 6 and 10")
+	ErrPeriod    = errors.New("totp: period must be a positive number of seconds")
+)
+
+// Key is a TOTP secret with its code parameters.
+type Key struct {
+	Secret    []byte
+	Algorithm string // SHA1, SHA256 or SHA512
+	Digits    int
+	Period    time.Duration
+	Issuer    string
+	Account   string
+}
+
+// base32NoPad decodes secrets, which authenticator URIs write unpadded.
+var base32NoPad = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// ParseSecret returns a Key with the usual defaults (SHA1, 6 digits, 30
+// seconds) for a base32 secret. Case, spaces and padding are ignored.
+func ParseSecret(secret string) (Key, error) {
+	s := strings.ToUpper(strings.NewReplacer(" ", "", "-", "", "=", "").Replace(secret))
+	b, err := base32NoPad.DecodeString(s)
+	if err != nil || len(b) == 0 {
+		return Key{}, ErrSecret
+	}
+	return Key{Secret: b, Algorithm: "SHA1", Digits: 6, Period: 30 * time.Second}, nil
+}
+
+// ParseURI reads an otpauth://totp/ URI.
+func ParseURI(uri string) (Key, error) {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "otpauth" || !strings.EqualFold(u.Host, "totp") {
+		return Key{}, ErrURI
+	}
+	q := u.Query()
+	k, err := ParseSecret(q.Get("secret"))
+	if err != nil {
+		return Key{}, err
+	}
+
+	label := strings.TrimPrefix(u.Path, "/")
+	if issuer, account, ok := strings.Cut(label, ":"); ok {
+		k.Issuer, k.Account = strings.TrimSpace(issuer), strings.TrimSpace(account)
+	} else {
+		k.Account = label
+	}
+	if v := q.Get("issuer"); v != "" {
+		k.Issuer = v
+	}
+	if v := q.Get("algorithm"); v != "" {
+		k.Algorithm = strings.ToUpper(v)
+		if k.hash() == nil {
+			return Key{}, ErrAlgorithm
+		}
+	}
+	if v := q.Get("digits"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 6 || n > 10 {
+			return Key{}, ErrDigits
+		}
+		k.Digits = n
+	}
+	if v := q.Get("period"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return Key{}, ErrPeriod
+		}
+		k.Period = time.Duration(n) * time.Second
+	}
+	return k, nil
+}
+
+// Parse accepts either an otpauth:// URI or a bare base32 secret.
+func Parse(s string) (Key, error) {
+	if strings.HasPrefix(strings.ToLower(s), "otpauth:") {
+		return ParseURI(s)
+	}
+	return ParseSecret(s)
+}
+
+// URI returns k as an otpauth://totp/ URI that ParseURI reads back.
+func (k Key) URI() string {
+	label := k.Account
+	if k.Issuer != "" {
+		label = k.Issuer + ":" + k.Account
+	}
+	q := url.Values{}
+	q.Set("secret", base32NoPad.EncodeToString(k.Secret))
+	if k.Issuer != "" {
+		q.Set("issuer", k.Issuer)
+	}
+	q.Set("algorithm", k.Algorithm)
+	q.Set("digits", strconv.Itoa(k.Digits))
+	q.Set("period", strconv.Itoa(int(k.Period/time.Second)))
+	u := url.URL{Scheme: "otpauth", Host: "totp", Path: "/" + label, RawQuery: q.Encode()}
+	return u.String()
+}
+
+// hash returns the HMAC hash constructor for k.Algorithm, or nil.
+func (k Key) hash() func() hash.Hash {
+	switch k.Algorithm {
+	case "SHA1":
+		return sha1.New
+	case "SHA256":
+		return sha256.New
+	case "SHA512":
+		return sha512.New
+	}
+	return nil
+}
+
+// Code returns the code valid at t and how much longer it stays valid.
+func (k Key) Code(t time.Time) (string, time.Duration) {
+	period := int64(k.Period / time.Second)
+	unix := t.Unix()
+	counter := unix / period
+	remaining := time.Duration(period-unix%period) * time.Second
+	return k.hotp(uint64(counter)), remaining
+}
+
+// hotp is the RFC 4226 HMAC-based one-time password for counter.
+func (k Key) hotp(counter uint64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+	mac := hmac.New(k.hash(), k.Secret)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	// Dynamic truncation: the low nibble of the last byte picks four
+	// bytes, whose top bit is dropped.
+	off := sum[len(sum)-1] & 0x0f
+	v := binary.BigEndian.Uint32(sum[off:]) & 0x7fffffff
+	mod := uint64(1)
+	for range k.Digits {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", k.Digits, uint64(v)%mod)
+}
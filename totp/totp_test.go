@@ -0,0 +1,91 @@
+// totp_test.go
+// RFC 6238 test vectors and otpauth URI round trips
+// Zarak Khan
+
+package totp
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// RFC 6238 Appendix B: 8-digit codes with a 30 second period. Each
+// algorithm uses the ASCII seed "1234567890" repeated to its key size.
+func TestCodeRFC6238(t *testing.T) {
+	keys := map[string]Key{
+		"SHA1":   {Secret: []byte(strings.Repeat("1234567890", 2)), Algorithm: "SHA1", Digits: 8, Period: 30 * time.Second},
+		"SHA256": {Secret: []byte(strings.Repeat("1234567890", 4)[:32]), Algorithm: "SHA256", Digits: 8, Period: 30 * time.Second},
+		"SHA512": {Secret: []byte(strings.Repeat("1234567890", 7)[:64]), Algorithm: "SHA512", Digits: 8, Period: 30 * time.Second},
+	}
+	tests := []struct {
+		unix int64
+		alg  string
+		want string
+	}{
+		{59, "SHA1", "94287082"},
+		{59, "SHA256", "46119246"},
+		{59, "SHA512", "90693936"},
+		{1111111109, "SHA1", "07081804"},
+		{1111111109, "SHA256", "68084774"},
+		{1111111109, "SHA512", "25091201"},
+		{1111111111, "SHA1", "14050471"},
+		{1111111111, "SHA256", "67062674"},
+		{1111111111, "SHA512", "99943326"},
+		{1234567890, "SHA1", "89005924"},
+		{1234567890, "SHA256", "91819424"},
+		{1234567890, "SHA512", "93441116"},
+		{2000000000, "SHA1", "69279037"},
+		{2000000000, "SHA256", "90698825"},
+		{2000000000, "SHA512", "38618901"},
+		{20000000000, "SHA1", "65353130"},
+		{20000000000, "SHA256", "77737706"},
+		{20000000000, "SHA512", "47863826"},
+	}
+	for _, tt := range tests {
+		got, _ := keys[tt.alg].Code(time.Unix(tt.unix, 0))
+		if got != tt.want {
+			t.Errorf("%s at %d: got %s, want %s", tt.alg, tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestCodeRemaining(t *testing.T) {
+	k := Key{Secret: []byte("12345678901234567890"), Algorithm: "SHA1", Digits: 6, Period: 30 * time.Second}
+	if _, left := k.Code(time.Unix(59, 0)); left != time.Second {
+		t.Errorf("remaining at t=59: got %s, want 1s", left)
+	}
+	if _, left := k.Code(time.Unix(60, 0)); left != 30*time.Second {
+		t.Errorf("remaining at t=60: got %s, want 30s", left)
+	}
+}
+
+func TestURIRoundTrip(t *testing.T) {
+	keys := []Key{
+		{Secret: []byte("12345678901234567890"), Algorithm: "SHA1", Digits: 6, Period: 30 * time.Second, Account: "alice@example.com"},
+		{Secret: []byte("another secret key"), Algorithm: "SHA512", Digits: 8, Period: 60 * time.Second, Issuer: "Example Co", Account: "bob"},
+	}
+	for _, k := range keys {
+		got, err := ParseURI(k.URI())
+		if err != nil {
+			t.Fatalf("ParseURI(%q): %v", k.URI(), err)
+		}
+		if string(got.Secret) != string(k.Secret) || got.Algorithm != k.Algorithm || got.Digits != k.Digits ||
+			got.Period != k.Period || got.Issuer != k.Issuer || got.Account != k.Account {
+			t.Errorf("round trip of %q: got %+v, want %+v", k.URI(), got, k)
+		}
+	}
+}
+
+func TestParseSecret(t *testing.T) {
+	k, err := ParseSecret("gezd gnbv gy3t qojq gezd gnbv gy3t qojq")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(k.Secret) != "12345678901234567890" {
+		t.Errorf("got secret %q", k.Secret)
+	}
+	if _, err := ParseSecret("not base32!"); err != ErrSecret {
+		t.Errorf("got %v, want ErrSecret", err)
+	}
+}
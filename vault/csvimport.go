@@ -0,0 +1,299 @@
+// csvimport.go
+// Import of CSV exports from browsers and other password managers
+// Zarak Khan
+
+package vault
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+)
+
+// DupPolicy decides what happens when an imported (site, user) pair is
+// already in the store.
+type DupPolicy int
+
+const (
+	DupSkip      DupPolicy = iota // keep the stored entry
+	DupOverwrite                  // replace the password, keeping it in history
+	DupRename                     // store the import under "user-2", "user-3", ...
+)
+
+// ErrDupPolicy is returned by ParseDupPolicy for an unknown policy.
+var ErrDupPolicy = errors.New("vault: unknown duplicate policy")
+
+// ParseDupPolicy converts "skip", "overwrite" or "rename" to a DupPolicy.
+func ParseDupPolicy(s string) (DupPolicy, error) {
+	switch s {
+	case "skip":
+		return DupSkip, nil
+	case "overwrite":
+		return DupOverwrite, nil
+	case "rename":
+		return DupRename, nil
+	}
+	return DupSkip, ErrDupPolicy
+}
+
+// ColumnMap names the CSV header column holding each Entry field; an
+// empty name means the export has no such column. When Site is missing
+// or blank the site is taken from the URL's host name.
+type ColumnMap struct {
+	Site, URL, User, Password, Notes, Tags string
+}
+
+// Layouts lists the export formats recognized by DetectLayout. The
+// browser layout is shared by Chrome, Edge, Brave and Safari; "pm" is
+// this program's own CSV export.
+var Layouts = []struct {
+	Name    string
+	Columns ColumnMap
+}{
+	{"pm", ColumnMap{Site: "site", URL: "url", User: "user", Password: "password", Notes: "notes", Tags: "tags"}},
+	{"browser", ColumnMap{Site: "name", URL: "url", User: "username", Password: "password", Notes: "note"}},
+	{"firefox", ColumnMap{URL: "url", User: "username", Password: "password"}},
+	{"bitwarden", ColumnMap{Site: "name", URL: "login_uri", User: "login_username", Password: "login_password", Notes: "notes", Tags: "folder"}},
+	{"lastpass", ColumnMap{Site: "name", URL: "url", User: "username", Password: "password", Notes: "extra", Tags: "grouping"}},
+	{"keepass", ColumnMap{Site: "title", URL: "url", User: "username", Password: "password", Notes: "notes", Tags: "group"}},
+	{"1password", ColumnMap{Site: "title", URL: "url", User: "username", Password: "password", Notes: "notes", Tags: "tags"}},
+}
+
+// ErrUnknownLayout is returned when a CSV header matches no known layout
+// and no ColumnMap was supplied.
+var ErrUnknownLayout = errors.New("vault: unrecognized CSV layout; give a column mapping")
+
+// ErrMissingColumn is returned when a mapped column is not in the header.
+var ErrMissingColumn = errors.New("vault: CSV header lacks a mapped column")
+
+// DetectLayout returns the most specific known layout whose columns all
+// appear in header, and its name.
+func DetectLayout(header []string) (ColumnMap, string, bool) {
+	idx := headerIndex(header)
+	best, bestName, bestCount := ColumnMap{}, "", 0
+	for _, l := range Layouts {
+		cols := l.Columns.names()
+		ok := true
+		for _, c := range cols {
+			if _, found := idx[c]; !found {
+				ok = false
+				break
+			}
+		}
+		if ok && len(cols) > bestCount {
+			best, bestName, bestCount = l.Columns, l.Name, len(cols)
+		}
+	}
+	return best, bestName, bestCount > 0
+}
+
+// ParseColumnMap reads a mapping such as "site=Title,user=Login,password=Pass".
+// Recognized keys are site, url, user, password, notes and tags.
+func ParseColumnMap(spec string) (ColumnMap, error) {
+	var m ColumnMap
+	for _, part := range strings.Split(spec, ",") {
+		key, col, ok := strings.Cut(part, "=")
+		col = strings.TrimSpace(col)
+		if !ok || col == "" {
+			return m, fmt.Errorf("vault: bad column mapping %q", part)
+		}
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "site":
+			m.Site = col
+		case "url":
+			m.URL = col
+		case "user":
+			m.User = col
+		case "password":
+			m.Password = col
+		case "notes":
+			m.Notes = col
+		case "tags":
+			m.Tags = col
+		default:
+			return m, fmt.Errorf("vault: unknown field %q in column mapping", key)
+		}
+	}
+	if m.Password == "" || (m.Site == "" && m.URL == "") {
+		return m, errors.New("vault: column mapping needs password and site or url")
+	}
+	return m, nil
+}
+
+// names returns the lower-cased column names that are set.
+func (m ColumnMap) names() []string {
+	var out []string
+	for _, c := range []string{m.Site, m.URL, m.User, m.Password, m.Notes, m.Tags} {
+		if c != "" {
+			out = append(out, strings.ToLower(c))
+		}
+	}
+	return out
+}
+
+// headerIndex maps lower-cased, trimmed header names to their position.
+func headerIndex(header []string) map[string]int {
+	idx := make(map[string]int, len(header))
+	for i, h := range header {
+		h = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(h, "\ufeff")))
+		if _, dup := idx[h]; !dup {
+			idx[h] = i
+		}
+	}
+	return idx
+}
+
+// CSVOptions control ImportCSV. A nil Columns means detect the layout from
+// the header row.
+type CSVOptions struct {
+	Columns     *ColumnMap
+	OnDuplicate DupPolicy
+}
+
+// ImportCSV adds the rows of a CSV export with a header row. Rows without
+// a password or site are skipped; duplicates are handled as
+// opts.OnDuplicate says. The returned layout names the detected format
+// ("custom" when opts.Columns was given).
+func (s *Store) ImportCSV(r io.Reader, opts CSVOptions) (ImportReport, string, error) {
+	var rep ImportReport
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err != nil {
+		return rep, "", err
+	}
+
+	cols, layout := ColumnMap{}, "custom"
+	if opts.Columns != nil {
+		cols = *opts.Columns
+	} else {
+		var ok bool
+		if cols, layout, ok = DetectLayout(header); !ok {
+			return rep, "", ErrUnknownLayout
+		}
+	}
+	idx := headerIndex(header)
+	for _, c := range cols.names() {
+		if _, ok := idx[c]; !ok {
+			return rep, layout, fmt.Errorf("%w: %q", ErrMissingColumn, c)
+		}
+	}
+	get := func(row []string, col string) string {
+		i, ok := idx[strings.ToLower(col)]
+		if col == "" || !ok || i >= len(row) {
+			return ""
+		}
+		return strings.TrimSpace(row[i])
+	}
+
+	for {
+		row, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var perr *csv.ParseError
+			if errors.As(err, &perr) {
+				rep.Skipped = append(rep.Skipped, LineIssue{Line: perr.Line, Reason: perr.Err.Error()})
+				continue
+			}
+			return rep, layout, err
+		}
+		line, _ := cr.FieldPos(0)
+
+		e := Entry{
+			Site:     get(row, cols.Site),
+			User:     get(row, cols.User),
+			Password: get(row, cols.Password),
+			Meta: Meta{
+				URL:   get(row, cols.URL),
+				Notes: get(row, cols.Notes),
+			},
+		}
+		if tags := get(row, cols.Tags); tags != "" {
+			for _, t := range strings.Split(tags, ",") {
+				if t = strings.TrimSpace(t); t != "" {
+					e.Tags = append(e.Tags, t)
+				}
+			}
+		}
+		if e.Site == "" {
+			e.Site = siteFromURL(e.URL)
+		}
+		switch {
+		case e.Site == "":
+			rep.Skipped = append(rep.Skipped, LineIssue{Line: line, Reason: "no site name or URL"})
+			continue
+		case e.Password == "":
+			rep.Skipped = append(rep.Skipped, LineIssue{Line: line, Text: issueText(e), Reason: "no password"})
+			continue
+		}
+		s.importEntry(e, LineIssue{Line: line, Text: issueText(e)}, opts.OnDuplicate, &rep)
+	}
+	return rep, layout, nil
+}
+
+// importEntry adds e, applying policy if (site, user) already exists and
+// recording the outcome in rep. It locks the store for each entry, so
+// other goroutines are not held up by a long import.
+func (s *Store) importEntry(e Entry, issue LineIssue, policy DupPolicy, rep *ImportReport) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t := now()
+	e.Created, e.Modified = t, t
+	if s.put(e) == nil {
+		rep.Added++
+		return
+	}
+
+	switch policy {
+	case DupOverwrite:
+		old := &s.sites[e.Site][s.index(e.Site, e.User)]
+		if e.Password != old.Password {
+			old.setPassword(e.Password, t)
+		}
+		if e.URL != "" {
+			old.URL = e.URL
+		}
+		if e.Notes != "" {
+			old.Notes = e.Notes
+		}
+		if len(e.Tags) > 0 {
+			old.Tags = e.Tags
+		}
+		old.Modified = t
+		issue.Reason = "replaced the existing entry for " + e.User + "@" + e.Site
+		rep.Overwritten = append(rep.Overwritten, issue)
+	case DupRename:
+		base := e.User
+		for n := 2; s.index(e.Site, e.User) != -1; n++ {
+			e.User = fmt.Sprintf("%s-%d", base, n)
+		}
+		s.put(e)
+		rep.Added++
+		issue.Reason = "stored as user " + e.User
+		rep.Renamed = append(rep.Renamed, issue)
+	default:
+		issue.Reason = ErrDuplicate.Error()
+		rep.Duplicates = append(rep.Duplicates, issue)
+	}
+}
+
+// siteFromURL returns the host name of a login URL without a leading
+// "www.", or "" if raw has none.
+func siteFromURL(raw string) string {
+	if raw == "" {
+		return ""
+	}
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+}
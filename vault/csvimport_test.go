@@ -0,0 +1,143 @@
+// csvimport_test.go
+// CSV layout detection and duplicate policies
+// Zarak Khan
+
+package vault
+
+import (
+	"encoding/csv"
+	"strings"
+	"testing"
+)
+
+func TestDetectLayout(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"name,url,username,password,note", "browser"},
+		{`"url","username","password","httpRealm","formActionOrigin","guid","timeCreated","timeLastUsed","timePasswordChanged"`, "firefox"},
+		{"folder,favorite,type,name,notes,fields,reprompt,login_uri,login_username,login_password,login_totp", "bitwarden"},
+		{"url,username,password,totp,extra,name,grouping,fav", "lastpass"},
+		{`"Group","Title","Username","Password","URL","Notes","TOTP","Icon","Last Modified","Created"`, "keepass"},
+		{"Title,Url,Username,Password,OTPAuth,Favorite,Archived,Tags,Notes", "1password"},
+		{"\ufeffsite,url,user,password,notes,tags", "pm"},
+	}
+	for _, tt := range tests {
+		header, err := csv.NewReader(strings.NewReader(tt.header)).Read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, name, ok := DetectLayout(header); !ok || name != tt.want {
+			t.Errorf("%s: got layout %q, want %q", tt.header, name, tt.want)
+		}
+	}
+
+	if _, _, err := New().ImportCSV(strings.NewReader("foo,bar\n"), CSVOptions{}); err != ErrUnknownLayout {
+		t.Errorf("unknown header: got %v, want ErrUnknownLayout", err)
+	}
+}
+
+func TestImportCSVSiteFromURL(t *testing.T) {
+	input := "url,username,password\n" +
+		"https://www.example.com/login,alice,pw1\n" +
+		"mail.example.org,bob,pw2\n" +
+		",carol,pw3\n"
+	s := New()
+	rep, layout, err := s.ImportCSV(strings.NewReader(input), CSVOptions{})
+	if err != nil || layout != "firefox" {
+		t.Fatalf("layout %q, %v", layout, err)
+	}
+	if rep.Added != 2 {
+		t.Errorf("added %d, want 2", rep.Added)
+	}
+	if e, err := s.Get("example.com", "alice"); err != nil || e.URL != "https://www.example.com/login" {
+		t.Errorf("example.com/alice: %+v, %v", e, err)
+	}
+	if _, err := s.Get("mail.example.org", "bob"); err != nil {
+		t.Errorf("mail.example.org/bob: %v", err)
+	}
+	if len(rep.Skipped) != 1 || rep.Skipped[0].Line != 4 {
+		t.Errorf("skipped %+v, want line 4", rep.Skipped)
+	}
+}
+
+// dupInput holds one entry and two rows that duplicate it.
+const dupInput = "name,url,username,password,note\n" +
+	"x,https://x.com,u,First!,\n" +
+	"x,https://x.com,u,Second!,changed note\n" +
+	"x,https://x.com,u,Third!,\n"
+
+func TestImportCSVDupSkip(t *testing.T) {
+	s := New()
+	rep, _, err := s.ImportCSV(strings.NewReader(dupInput), CSVOptions{OnDuplicate: DupSkip})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.Added != 1 || len(rep.Duplicates) != 2 || s.Len() != 1 {
+		t.Fatalf("report %+v, store has %d", rep, s.Len())
+	}
+	if rep.Duplicates[0].Line != 3 || rep.Duplicates[1].Line != 4 {
+		t.Errorf("duplicate lines %+v, want 3 and 4", rep.Duplicates)
+	}
+	for _, l := range rep.Duplicates {
+		if strings.Contains(l.Text, "!") {
+			t.Errorf("line %d reports a password: %q", l.Line, l.Text)
+		}
+	}
+	if e, _ := s.Get("x", "u"); e.Password != "First!" {
+		t.Errorf("password %q, want First!", e.Password)
+	}
+}
+
+func TestImportCSVDupOverwrite(t *testing.T) {
+	s := New()
+	rep, _, err := s.ImportCSV(strings.NewReader(dupInput), CSVOptions{OnDuplicate: DupOverwrite})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.Added != 1 || len(rep.Overwritten) != 2 || len(rep.Duplicates) != 0 || s.Len() != 1 {
+		t.Fatalf("report %+v, store has %d", rep, s.Len())
+	}
+	e, _ := s.Get("x", "u")
+	if e.Password != "Third!" || e.Notes != "changed note" {
+		t.Errorf("entry %+v, want password Third! and the changed note", e)
+	}
+	if len(e.History) != 2 || e.History[0].Password != "First!" || e.History[1].Password != "Second!" {
+		t.Errorf("history %+v, want First! then Second!", e.History)
+	}
+}
+
+func TestImportCSVDupRename(t *testing.T) {
+	s := New()
+	rep, _, err := s.ImportCSV(strings.NewReader(dupInput), CSVOptions{OnDuplicate: DupRename})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.Added != 3 || len(rep.Renamed) != 2 || s.Len() != 3 {
+		t.Fatalf("report %+v, store has %d", rep, s.Len())
+	}
+	for user, want := range map[string]string{"u": "First!", "u-2": "Second!", "u-3": "Third!"} {
+		if e, err := s.Get("x", user); err != nil || e.Password != want {
+			t.Errorf("user %s: %+v, %v; want password %s", user, e, err, want)
+		}
+	}
+	if !strings.Contains(rep.Renamed[0].Reason, "u-2") || !strings.Contains(rep.Renamed[1].Reason, "u-3") {
+		t.Errorf("renamed %+v, want u-2 then u-3", rep.Renamed)
+	}
+}
+
+func TestParseColumnMap(t *testing.T) {
+	m, err := ParseColumnMap("site=Title, user=Login,password=Pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m != (ColumnMap{Site: "Title", User: "Login", Password: "Pass"}) {
+		t.Errorf("got %+v", m)
+	}
+	for _, bad := range []string{"site=Title", "password=Pass", "color=Red,password=P,site=S", "site"} {
+		if _, err := ParseColumnMap(bad); err == nil {
+			t.Errorf("%q: expected an error", bad)
+		}
+	}
+}
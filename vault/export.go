@@ -0,0 +1,78 @@
+// export.go
+// Plaintext exports: legacy "site user pass", CSV and JSON
+// Zarak Khan
+
+package vault
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+)
+
+// ErrExportFormat is returned by Export for an unknown format name.
+var ErrExportFormat = errors.New("vault: unknown export format")
+
+// ExportFormats lists the names accepted by Export.
+var ExportFormats = []string{"legacy", "csv", "json"}
+
+// csvHeader is the header row written by ExportCSV. It matches the "pm"
+// layout, so ImportCSV reads an export back without a column mapping.
+var csvHeader = []string{"site", "url", "user", "password", "notes", "tags"}
+
+// Export writes every entry to w, unencrypted, in the named format.
+func (s *Store) Export(w io.Writer, format string) error {
+	switch format {
+	case "legacy":
+		return s.ExportLegacy(w)
+	case "csv":
+		return s.ExportCSV(w)
+	case "json":
+		return s.ExportJSON(w)
+	}
+	return ErrExportFormat
+}
+
+// ExportLegacy writes one "site user pass" line per entry in the format
+// ImportLegacy reads. Metadata and history are not included.
+func (s *Store) ExportLegacy(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	for _, e := range s.List() {
+		bw.WriteString(FormatLegacyLine(e))
+		bw.WriteByte('\n')
+	}
+	return bw.Flush()
+}
+
+// ExportCSV writes a header row and one row per entry. Custom fields and
+// history are not included.
+func (s *Store) ExportCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write(csvHeader)
+	for _, e := range s.List() {
+		cw.Write([]string{e.Site, e.URL, e.User, e.Password, e.Notes, strings.Join(e.Tags, ",")})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// ExportJSON writes the complete store, including custom fields and
+// password history, as an indented JSON array in the same schema as the
+// encrypted vault payload.
+func (s *Store) ExportJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(s.records())
+}
+
+// ReadJSON reads the output of ExportJSON back into a new Store.
+func ReadJSON(r io.Reader) (*Store, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return unmarshalStore(data)
+}
@@ -0,0 +1,361 @@
+// file.go
+// Encrypted, versioned vault file format
+// Zarak Khan
+//
+// Layout (all integers big-endian):
+//
+//	magic    8 bytes  "PMVAULT\x00"
+//	version  1 byte   Version
+//	kdf      1 byte   kdfScrypt
+//	logN     1 byte   scrypt cost, N = 1<<logN
+//	r        4 bytes  scrypt block size
+//	p        4 bytes  scrypt parallelism
+//	saltLen  1 byte   followed by saltLen bytes of salt
+//	nonce    12 bytes AES-GCM nonce
+//	payload  rest     AES-256-GCM ciphertext of the JSON entry list
+//
+// Everything before the payload is passed to GCM as additional data, so
+// tampering with the KDF parameters or salt is detected on open.
+
+package vault
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	vaultMagic = "PMVAULT\x00"
+	kdfScrypt  = 1
+	saltSize   = 16
+	keySize    = 32
+)
+
+// Version is the vault file format version written by Seal.
+const Version = 1
+
+// KDFParams are the scrypt cost parameters stored in the vault header.
+// N is 1<<LogN.
+type KDFParams struct {
+	LogN uint8
+	R, P uint32
+}
+
+// DefaultKDF costs roughly 32 MiB of memory per derivation.
+var DefaultKDF = KDFParams{LogN: 15, R: 8, P: 1}
+
+// Errors returned when opening a vault file.
+var (
+	ErrFormat   = errors.New("vault: not a valid vault file")
+	ErrVersion  = errors.New("vault: unsupported vault version")
+	ErrPassword = errors.New("vault: incorrect master password or corrupted vault")
+)
+
+// vaultRecord is the on-disk form of an Entry inside the encrypted payload.
+type vaultRecord struct {
+	Site     string          `json:"site"`
+	User     string          `json:"user"`
+	Password string          `json:"password"`
+	URL      string          `json:"url,omitempty"`
+	Notes    string          `json:"notes,omitempty"`
+	Tags     []string        `json:"tags,omitempty"`
+	Fields   []fieldRecord   `json:"fields,omitempty"`
+	TOTP     string          `json:"totp,omitempty"`
+	Created  time.Time       `json:"created,omitzero"`
+	Modified time.Time       `json:"modified,omitzero"`
+	History  []historyRecord `json:"history,omitempty"`
+}
+
+// fieldRecord is the on-disk form of a Field.
+type fieldRecord struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Secret bool   `json:"secret,omitempty"`
+}
+
+// historyRecord is the on-disk form of a PasswordChange.
+type historyRecord struct {
+	Password string    `json:"password"`
+	Replaced time.Time `json:"replaced"`
+}
+
+// vaultHeader is the parsed plaintext header of a vault file.
+type vaultHeader struct {
+	kdf   KDFParams
+	salt  []byte
+	nonce []byte
+}
+
+// IsVault reports whether data starts with the vault magic bytes.
+func IsVault(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(vaultMagic))
+}
+
+// marshal encodes the header exactly as it is written to disk.
+func (h vaultHeader) marshal() []byte {
+	var buf bytes.Buffer
+	buf.WriteString(vaultMagic)
+	buf.WriteByte(Version)
+	buf.WriteByte(kdfScrypt)
+	buf.WriteByte(h.kdf.LogN)
+	binary.Write(&buf, binary.BigEndian, h.kdf.R)
+	binary.Write(&buf, binary.BigEndian, h.kdf.P)
+	buf.WriteByte(byte(len(h.salt)))
+	buf.Write(h.salt)
+	buf.Write(h.nonce)
+	return buf.Bytes()
+}
+
+// parseVaultHeader splits data into its header and the ciphertext that
+// follows it.
+func parseVaultHeader(data []byte) (vaultHeader, []byte, error) {
+	var h vaultHeader
+	if !IsVault(data) {
+		return h, nil, ErrFormat
+	}
+	rest := data[len(vaultMagic):]
+	if len(rest) < 3+4+4+1 {
+		return h, nil, ErrFormat
+	}
+	if rest[0] != Version {
+		return h, nil, ErrVersion
+	}
+	if rest[1] != kdfScrypt {
+		return h, nil, ErrFormat
+	}
+	h.kdf.LogN = rest[2]
+	h.kdf.R = binary.BigEndian.Uint32(rest[3:])
+	h.kdf.P = binary.BigEndian.Uint32(rest[7:])
+	saltLen := int(rest[11])
+	rest = rest[12:]
+	if len(rest) < saltLen+12 {
+		return h, nil, ErrFormat
+	}
+	h.salt = rest[:saltLen]
+	h.nonce = rest[saltLen : saltLen+12]
+	return h, rest[saltLen+12:], nil
+}
+
+// Limits on the scrypt parameters a vault may ask for. The header is read
+// before it can be authenticated, so a tampered file must not be able to
+// make deriveKey allocate more than maxKDFMemory or run for hours.
+const (
+	maxKDFMemory = 1 << 30 // bytes, 128*N*r
+	maxKDFP      = 16
+)
+
+// Check returns ErrKDFParams unless kp is within the limits deriveKey
+// accepts.
+func (kp KDFParams) Check() error {
+	if kp.LogN < 1 || kp.LogN > 30 || kp.R == 0 || kp.P == 0 || kp.P > maxKDFP {
+		return ErrKDFParams
+	}
+	if uint64(kp.R) > (maxKDFMemory/128)>>kp.LogN {
+		return ErrKDFParams
+	}
+	return nil
+}
+
+// deriveKey stretches the master password into an AES-256 key.
+func deriveKey(password string, salt []byte, kp KDFParams) ([]byte, error) {
+	if err := kp.Check(); err != nil {
+		return nil, err
+	}
+	return scryptKey(password, salt, 1<<kp.LogN, int(kp.R), int(kp.P), keySize)
+}
+
+// newGCM returns an AES-GCM AEAD for key.
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// records converts the store to its on-disk form, sorted by site and user.
+func (s *Store) records() []vaultRecord {
+	records := []vaultRecord{}
+	for _, e := range s.List() {
+		r := vaultRecord{
+			Site: e.Site, User: e.User, Password: e.Password,
+			URL: e.URL, Notes: e.Notes, Tags: e.Tags, TOTP: e.TOTP,
+			Created: e.Created, Modified: e.Modified,
+		}
+		for _, f := range e.Fields {
+			r.Fields = append(r.Fields, fieldRecord{Name: f.Name, Value: f.Value, Secret: f.Secret})
+		}
+		for _, h := range e.History {
+			r.History = append(r.History, historyRecord{Password: h.Password, Replaced: h.Replaced})
+		}
+		records = append(records, r)
+	}
+	return records
+}
+
+// Seal encrypts the store under password using a fresh salt and nonce,
+// returning the complete vault file contents.
+func (s *Store) Seal(password string, kp KDFParams) ([]byte, error) {
+	plain, err := json.Marshal(s.records())
+	if err != nil {
+		return nil, err
+	}
+
+	h := vaultHeader{kdf: kp, salt: make([]byte, saltSize), nonce: make([]byte, 12)}
+	if _, err := rand.Read(h.salt); err != nil {
+		return nil, err
+	}
+	if _, err := rand.Read(h.nonce); err != nil {
+		return nil, err
+	}
+	key, err := deriveKey(password, h.salt, kp)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	header := h.marshal()
+	out := append([]byte(nil), header...)
+	return aead.Seal(out, h.nonce, plain, header), nil
+}
+
+// Unseal authenticates and decrypts vault file contents.
+func Unseal(data []byte, password string) (*Store, error) {
+	h, ciphertext, err := parseVaultHeader(data)
+	if err != nil {
+		return nil, err
+	}
+	key, err := deriveKey(password, h.salt, h.kdf)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	header := data[:len(data)-len(ciphertext)]
+	plain, err := aead.Open(nil, h.nonce, ciphertext, header)
+	if err != nil {
+		return nil, ErrPassword
+	}
+
+	s, err := unmarshalStore(plain)
+	if err != nil {
+		return nil, err
+	}
+	s.kdf = h.kdf
+	return s, nil
+}
+
+// unmarshalStore decodes a JSON entry list in the vaultRecord schema.
+func unmarshalStore(data []byte) (*Store, error) {
+	var records []vaultRecord
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, ErrFormat
+	}
+	s := New()
+	for _, r := range records {
+		e := Entry{
+			Site: r.Site, User: r.User, Password: r.Password,
+			Meta:    Meta{URL: r.URL, Notes: r.Notes, Tags: r.Tags, TOTP: r.TOTP},
+			Created: r.Created, Modified: r.Modified,
+		}
+		for _, f := range r.Fields {
+			e.Fields = append(e.Fields, Field{Name: f.Name, Value: f.Value, Secret: f.Secret})
+		}
+		for _, h := range r.History {
+			e.History = append(e.History, PasswordChange{Password: h.Password, Replaced: h.Replaced})
+		}
+		if err := s.put(e); err != nil {
+			return nil, ErrFormat
+		}
+	}
+	return s, nil
+}
+
+// Open reads and decrypts the vault file at path.
+func Open(path, password string) (*Store, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Unseal(data, password)
+}
+
+// KDF returns the cost parameters the store is saved with: those of the
+// vault file it was opened from, or DefaultKDF for a new store.
+func (s *Store) KDF() KDFParams {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.kdf == (KDFParams{}) {
+		return DefaultKDF
+	}
+	return s.kdf
+}
+
+// Save seals the store with its KDF parameters and atomically replaces
+// the file at path.
+func (s *Store) Save(path, password string) error {
+	data, err := s.Seal(password, s.KDF())
+	if err != nil {
+		return err
+	}
+	return WriteFileAtomic(path, data, 0600)
+}
+
+// Rekey re-encrypts the store under a new master password and KDF cost
+// and atomically replaces the file at path. The new parameters are kept
+// for later saves. Verifying the old password is up to the caller, who
+// must have opened the store with it.
+func (s *Store) Rekey(path, newPassword string, kp KDFParams) error {
+	data, err := s.Seal(newPassword, kp)
+	if err != nil {
+		return err
+	}
+	if err := WriteFileAtomic(path, data, 0600); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.kdf = kp
+	s.mu.Unlock()
+	return nil
+}
+
+// WriteFileAtomic writes data to a temporary file in the same directory as
+// path and renames it into place, so a crash mid-write never leaves a
+// truncated vault behind.
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	// Remove the temp file on any failure; after a successful rename this
+	// is a harmless no-op.
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
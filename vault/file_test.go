@@ -0,0 +1,119 @@
+// file_test.go
+// Sealing and opening encrypted vault files
+// Zarak Khan
+
+package vault
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+// testKDF keeps the tests fast; real vaults use DefaultKDF.
+var testKDF = KDFParams{LogN: 4, R: 1, P: 1}
+
+func sealedTestStore(t *testing.T) []byte {
+	t.Helper()
+	s := New()
+	if err := s.Add("a.com", "bob", "first"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Update("a.com", "bob", "", "second"); err != nil {
+		t.Fatal(err)
+	}
+	m := Meta{URL: "https://a.com/login", Notes: "work account", Tags: []string{"work", "mail"}, TOTP: "otpauth://totp/a?secret=GEZDGNBV"}
+	m.SetField(Field{Name: "pin", Value: "1234", Secret: true})
+	if err := s.SetMeta("a.com", "bob", m); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Add("b.com", "carol", "third"); err != nil {
+		t.Fatal(err)
+	}
+	data, err := s.Seal("master", testKDF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestSealUnsealRoundTrip(t *testing.T) {
+	data := sealedTestStore(t)
+	if !IsVault(data) {
+		t.Fatal("sealed data lacks the vault magic")
+	}
+	s, err := Unseal(data, "master")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Len() != 2 || s.KDF() != testKDF {
+		t.Fatalf("got %d entries with %+v", s.Len(), s.KDF())
+	}
+	e, err := s.Get("a.com", "bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Password != "second" || e.URL != "https://a.com/login" || e.Notes != "work account" ||
+		!slices.Equal(e.Tags, []string{"work", "mail"}) || e.TOTP != "otpauth://totp/a?secret=GEZDGNBV" {
+		t.Errorf("entry not kept: %+v", e)
+	}
+	if f, ok := e.Field("pin"); !ok || f.Value != "1234" || !f.Secret {
+		t.Errorf("custom field not kept: %+v", e.Fields)
+	}
+	if len(e.History) != 1 || e.History[0].Password != "first" || e.History[0].Replaced.IsZero() {
+		t.Errorf("history not kept: %+v", e.History)
+	}
+	if e.Created.IsZero() || e.Modified.IsZero() {
+		t.Errorf("timestamps not kept: created %v, modified %v", e.Created, e.Modified)
+	}
+}
+
+func TestUnsealWrongPassword(t *testing.T) {
+	if _, err := Unseal(sealedTestStore(t), "wrong"); !errors.Is(err, ErrPassword) {
+		t.Errorf("got %v, want ErrPassword", err)
+	}
+}
+
+func TestUnsealTamperedHeader(t *testing.T) {
+	const (
+		logNOffset = len(vaultMagic) + 2
+		saltOffset = len(vaultMagic) + 3 + 4 + 4 + 1
+	)
+	for name, tamper := range map[string]func([]byte){
+		"logN": func(d []byte) { d[logNOffset]++ },
+		"salt": func(d []byte) { d[saltOffset] ^= 1 },
+	} {
+		data := sealedTestStore(t)
+		tamper(data)
+		if _, err := Unseal(data, "master"); !errors.Is(err, ErrPassword) {
+			t.Errorf("%s changed: got %v, want ErrPassword", name, err)
+		}
+	}
+}
+
+func TestKDFParamsCheck(t *testing.T) {
+	tests := []struct {
+		kp KDFParams
+		ok bool
+	}{
+		{DefaultKDF, true},
+		{testKDF, true},
+		{KDFParams{LogN: 20, R: 8, P: 1}, true},   // exactly 1 GiB
+		{KDFParams{LogN: 20, R: 9, P: 1}, false},  // above 1 GiB
+		{KDFParams{LogN: 21, R: 8, P: 1}, false},  // above 1 GiB
+		{KDFParams{LogN: 31, R: 1, P: 1}, false},  // logN out of range
+		{KDFParams{LogN: 15, R: 8, P: 17}, false}, // too parallel
+		{KDFParams{LogN: 0, R: 8, P: 1}, false},
+		{KDFParams{LogN: 15, R: 0, P: 1}, false},
+		{KDFParams{LogN: 15, R: 8, P: 0}, false},
+	}
+	for _, tt := range tests {
+		err := tt.kp.Check()
+		if tt.ok && err != nil {
+			t.Errorf("%+v: unexpected %v", tt.kp, err)
+		}
+		if !tt.ok && !errors.Is(err, ErrKDFParams) {
+			t.Errorf("%+v: got %v, want ErrKDFParams", tt.kp, err)
+		}
+	}
+}
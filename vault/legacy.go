@@ -0,0 +1,165 @@
+// legacy.go
+// Import and export of the original plaintext "site user pass" format
+// Zarak Khan
+//
+// Each line holds three whitespace-separated fields. Blank lines and
+// lines starting with '#' are ignored. A field containing whitespace,
+// quotes or a leading '#' can be written in double quotes, where \" \\
+// \n and \t are recognized, or in single quotes, taken literally. Outside
+// quotes a backslash escapes the next character.
+
+package vault
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// LineIssue describes an input line that was not imported.
+type LineIssue struct {
+	Line   int    // 1-based line number
+	Text   string // the line's site and user, never its password; may be empty
+	Reason string
+}
+
+// ImportReport summarizes a legacy or CSV import.
+type ImportReport struct {
+	Added       int
+	Skipped     []LineIssue // malformed lines
+	Duplicates  []LineIssue // (site, user) pairs already present and kept
+	Overwritten []LineIssue // duplicates replaced under DupOverwrite
+	Renamed     []LineIssue // duplicates added under a new user name
+}
+
+var (
+	errUnterminated = errors.New("unterminated quote")
+	errTrailingEsc  = errors.New("backslash at end of line")
+)
+
+// ImportLegacy adds every entry from r. Malformed lines and duplicates are
+// recorded in the report rather than failing the import; the error is
+// only set if reading r fails.
+func (s *Store) ImportLegacy(r io.Reader) (ImportReport, error) {
+	var rep ImportReport
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		text := scanner.Text()
+		trimmed := strings.TrimSpace(text)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		fields, err := splitLegacyLine(text)
+		if err == nil && len(fields) != 3 {
+			err = fmt.Errorf("expected 3 fields, found %d", len(fields))
+		}
+		if err != nil {
+			// Which field of a malformed line is the password cannot be
+			// told, so none of it is reported.
+			rep.Skipped = append(rep.Skipped, LineIssue{Line: n, Reason: err.Error()})
+			continue
+		}
+		e := Entry{Site: fields[0], User: fields[1], Password: fields[2]}
+		s.importEntry(e, LineIssue{Line: n, Text: issueText(e)}, DupSkip, &rep)
+	}
+	return rep, scanner.Err()
+}
+
+// issueText identifies e in a LineIssue by its site and user only.
+func issueText(e Entry) string {
+	return QuoteLegacyField(e.Site) + " " + QuoteLegacyField(e.User)
+}
+
+// splitLegacyLine splits one line into fields, honoring quotes and
+// backslash escapes.
+func splitLegacyLine(line string) ([]string, error) {
+	var fields []string
+	var cur strings.Builder
+	inField := false
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\r':
+			if inField {
+				fields = append(fields, cur.String())
+				cur.Reset()
+				inField = false
+			}
+		case c == '\\':
+			if i+1 == len(line) {
+				return nil, errTrailingEsc
+			}
+			i++
+			cur.WriteByte(line[i])
+			inField = true
+		case c == '\'':
+			end := strings.IndexByte(line[i+1:], '\'')
+			if end < 0 {
+				return nil, errUnterminated
+			}
+			cur.WriteString(line[i+1 : i+1+end])
+			i += end + 1
+			inField = true
+		case c == '"':
+			j, err := readDoubleQuoted(line, i+1, &cur)
+			if err != nil {
+				return nil, err
+			}
+			i = j
+			inField = true
+		default:
+			cur.WriteByte(c)
+			inField = true
+		}
+	}
+	if inField {
+		fields = append(fields, cur.String())
+	}
+	return fields, nil
+}
+
+// readDoubleQuoted copies a double-quoted string starting at line[i] into
+// b and returns the index of the closing quote.
+func readDoubleQuoted(line string, i int, b *strings.Builder) (int, error) {
+	for ; i < len(line); i++ {
+		switch c := line[i]; c {
+		case '"':
+			return i, nil
+		case '\\':
+			if i+1 == len(line) {
+				return 0, errUnterminated
+			}
+			i++
+			switch line[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			default:
+				b.WriteByte(line[i])
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return 0, errUnterminated
+}
+
+// QuoteLegacyField returns f in a form splitLegacyLine reads back
+// unchanged, quoting it only when necessary.
+func QuoteLegacyField(f string) string {
+	if f != "" && !strings.ContainsAny(f, " \t\n\"'\\") && !strings.HasPrefix(f, "#") {
+		return f
+	}
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`)
+	return `"` + r.Replace(f) + `"`
+}
+
+// FormatLegacyLine formats e as one "site user pass" line, without the
+// trailing newline.
+func FormatLegacyLine(e Entry) string {
+	return QuoteLegacyField(e.Site) + " " + QuoteLegacyField(e.User) + " " + QuoteLegacyField(e.Password)
+}
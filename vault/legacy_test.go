@@ -0,0 +1,112 @@
+// legacy_test.go
+// Parsing, quoting and importing the plaintext "site user pass" format
+// Zarak Khan
+
+package vault
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSplitLegacyLine(t *testing.T) {
+	tests := []struct {
+		line string
+		want []string
+		err  error
+	}{
+		{"a.com bob pw", []string{"a.com", "bob", "pw"}, nil},
+		{"  a.com\tbob   pw\r", []string{"a.com", "bob", "pw"}, nil},
+		{`'My Bank' 'bob smith' 'it''s'`, []string{"My Bank", "bob smith", "its"}, nil},
+		{`'no \escapes' x y`, []string{`no \escapes`, "x", "y"}, nil},
+		{`"My Bank" bob "p w"`, []string{"My Bank", "bob", "p w"}, nil},
+		{`a.com bob "say \"hi\""`, []string{"a.com", "bob", `say "hi"`}, nil},
+		{`a.com bob "back\\slash"`, []string{"a.com", "bob", `back\slash`}, nil},
+		{`a.com bob "line\nbreak"`, []string{"a.com", "bob", "line\nbreak"}, nil},
+		{`a.com bob "tab\there"`, []string{"a.com", "bob", "tab\there"}, nil},
+		{`a.com bob pass\ word`, []string{"a.com", "bob", "pass word"}, nil},
+		{`a.com bob ""`, []string{"a.com", "bob", ""}, nil},
+		{`a.com bob pw\`, nil, errTrailingEsc},
+		{`a.com bob "pw`, nil, errUnterminated},
+		{`a.com bob "pw\`, nil, errUnterminated},
+		{`a.com bob 'pw`, nil, errUnterminated},
+		{"", nil, nil},
+	}
+	for _, tt := range tests {
+		got, err := splitLegacyLine(tt.line)
+		if err != tt.err {
+			t.Errorf("%q: got error %v, want %v", tt.line, err, tt.err)
+			continue
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%q: got %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestFormatLegacyLineRoundTrip(t *testing.T) {
+	entries := []Entry{
+		{Site: "a.com", User: "bob", Password: "plain"},
+		{Site: "My Bank", User: "bob smith", Password: "has space"},
+		{Site: "a.com", User: "bob", Password: ""},
+		{Site: "a.com", User: "bob", Password: "tab\there"},
+		{Site: "a.com", User: "bob", Password: "new\nline"},
+		{Site: "a.com", User: "bob", Password: `q"uo'te\s`},
+		{Site: "#notacomment", User: "bob", Password: "#hash"},
+	}
+	for _, e := range entries {
+		line := FormatLegacyLine(e)
+		got, err := splitLegacyLine(line)
+		want := []string{e.Site, e.User, e.Password}
+		if err != nil || !slices.Equal(got, want) {
+			t.Errorf("%q: got %q (%v), want %q", line, got, err, want)
+		}
+	}
+}
+
+func TestImportLegacyReport(t *testing.T) {
+	input := strings.Join([]string{
+		"# a comment",
+		"a.com bob first-secret",
+		"",
+		"   # an indented comment",
+		`"bad quote-secret`,
+		"a.com bob dup-secret",
+		"only-two two-secret",
+		`"My Bank" 'bob smith' bank-secret`,
+	}, "\n")
+	s := New()
+	rep, err := s.ImportLegacy(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.Added != 2 || s.Len() != 2 {
+		t.Errorf("added %d, store has %d; want 2", rep.Added, s.Len())
+	}
+	if e, err := s.Get("My Bank", "bob smith"); err != nil || e.Password != "bank-secret" {
+		t.Errorf("quoted entry: %+v, %v", e, err)
+	}
+
+	wantSkipped := []int{5, 7}
+	if len(rep.Skipped) != len(wantSkipped) {
+		t.Fatalf("skipped %+v, want lines %v", rep.Skipped, wantSkipped)
+	}
+	for i, l := range rep.Skipped {
+		if l.Line != wantSkipped[i] {
+			t.Errorf("skipped line %d, want %d", l.Line, wantSkipped[i])
+		}
+	}
+	if len(rep.Duplicates) != 1 || rep.Duplicates[0].Line != 6 || rep.Duplicates[0].Text != "a.com bob" {
+		t.Errorf("duplicates %+v, want line 6 as a.com bob", rep.Duplicates)
+	}
+
+	var issues []LineIssue
+	issues = append(issues, rep.Skipped...)
+	issues = append(issues, rep.Duplicates...)
+	for _, l := range issues {
+		if strings.Contains(l.Text, "secret") || strings.Contains(l.Reason, "secret") {
+			t.Errorf("line %d reports a password: %+v", l.Line, l)
+		}
+	}
+}
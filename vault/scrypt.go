@@ -0,0 +1,123 @@
+// scrypt.go
+// scrypt key derivation (RFC 7914) built on the standard library
+// Zarak Khan
+
+package vault
+
+import (
+	"crypto/pbkdf2"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"math/bits"
+)
+
+// ErrKDFParams is returned for scrypt parameters that are out of range.
+var ErrKDFParams = errors.New("vault: invalid scrypt parameters")
+
+// salsaXOR applies the Salsa20/8 core to tmp ^ in, writing the result to
+// both tmp and out.
+func salsaXOR(tmp *[16]uint32, in, out []uint32) {
+	var w, x [16]uint32
+	for i := range w {
+		w[i] = tmp[i] ^ in[i]
+	}
+	x = w
+
+	// quarter applies one Salsa20 quarter-round to the given positions.
+	quarter := func(a, b, c, d int) {
+		x[b] ^= bits.RotateLeft32(x[a]+x[d], 7)
+		x[c] ^= bits.RotateLeft32(x[b]+x[a], 9)
+		x[d] ^= bits.RotateLeft32(x[c]+x[b], 13)
+		x[a] ^= bits.RotateLeft32(x[d]+x[c], 18)
+	}
+	for i := 0; i < 8; i += 2 {
+		// Column round.
+		quarter(0, 4, 8, 12)
+		quarter(5, 9, 13, 1)
+		quarter(10, 14, 2, 6)
+		quarter(15, 3, 7, 11)
+		// Row round.
+		quarter(0, 1, 2, 3)
+		quarter(5, 6, 7, 4)
+		quarter(10, 11, 8, 9)
+		quarter(15, 12, 13, 14)
+	}
+
+	for i := range x {
+		x[i] += w[i]
+		out[i] = x[i]
+		tmp[i] = x[i]
+	}
+}
+
+// blockMix is the scrypt BlockMix function over 2*r 64-byte blocks.
+func blockMix(tmp *[16]uint32, in, out []uint32, r int) {
+	copy(tmp[:], in[(2*r-1)*16:])
+	for i := 0; i < 2*r; i += 2 {
+		salsaXOR(tmp, in[i*16:], out[i*8:])
+		salsaXOR(tmp, in[i*16+16:], out[i*8+r*16:])
+	}
+}
+
+// integerify returns the little-endian value of the last 64-byte block.
+func integerify(b []uint32, r int) uint64 {
+	j := (2*r - 1) * 16
+	return uint64(b[j]) | uint64(b[j+1])<<32
+}
+
+// smix is the scrypt ROMix function; v is the N*128*r byte scratch area
+// that makes the derivation memory-hard.
+func smix(b []byte, r, n int, v, xy []uint32) {
+	var tmp [16]uint32
+	R := 32 * r
+	x, y := xy[:R], xy[R:]
+
+	for i := range x {
+		x[i] = binary.LittleEndian.Uint32(b[i*4:])
+	}
+	for i := 0; i < n; i += 2 {
+		copy(v[i*R:], x)
+		blockMix(&tmp, x, y, r)
+		copy(v[(i+1)*R:], y)
+		blockMix(&tmp, y, x, r)
+	}
+	for i := 0; i < n; i += 2 {
+		j := int(integerify(x, r) & uint64(n-1))
+		for k, w := range v[j*R : j*R+R] {
+			x[k] ^= w
+		}
+		blockMix(&tmp, x, y, r)
+		j = int(integerify(y, r) & uint64(n-1))
+		for k, w := range v[j*R : j*R+R] {
+			y[k] ^= w
+		}
+		blockMix(&tmp, y, x, r)
+	}
+	for i, w := range x {
+		binary.LittleEndian.PutUint32(b[i*4:], w)
+	}
+}
+
+// scryptKey derives a keyLen-byte key from password and salt. n must be a
+// power of two greater than one.
+func scryptKey(password string, salt []byte, n, r, p, keyLen int) ([]byte, error) {
+	const maxInt = int(^uint(0) >> 1)
+	if n <= 1 || n&(n-1) != 0 || r <= 0 || p <= 0 {
+		return nil, ErrKDFParams
+	}
+	if uint64(r)*uint64(p) >= 1<<30 || r > maxInt/128/p || r > maxInt/256 || n > maxInt/128/r {
+		return nil, ErrKDFParams
+	}
+
+	xy := make([]uint32, 64*r)
+	v := make([]uint32, 32*n*r)
+	b, err := pbkdf2.Key(sha256.New, password, salt, 1, p*128*r)
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < p; i++ {
+		smix(b[i*128*r:], r, n, v, xy)
+	}
+	return pbkdf2.Key(sha256.New, password, b, 1, keyLen)
+}
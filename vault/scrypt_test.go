@@ -0,0 +1,49 @@
+// scrypt_test.go
+// RFC 7914 test vectors for the scrypt implementation
+// Zarak Khan
+
+package vault
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+// Vectors from RFC 7914 section 12.
+func TestScryptRFC7914(t *testing.T) {
+	tests := []struct {
+		password, salt string
+		n, r, p        int
+		want           string
+		slow           bool
+	}{
+		{"", "", 16, 1, 1,
+			"77d6576238657b203b19ca42c18a0497f16b4844e3074ae8dfdffa3fede21442fcd0069ded0948f8326a753a0fc81f17e8d3e0fb2e0d3628cf35e20c38d18906", false},
+		{"password", "NaCl", 1024, 8, 16,
+			"fdbabe1c9d3472007856e7190d01e9fe7c6ad7cbc8237830e77376634b3731622eaf30d92e22a3886ff109279d9830dac727afb94a83ee6d8360cbdfa2cc0640", false},
+		{"pleaseletmein", "SodiumChloride", 16384, 8, 1,
+			"7023bdcb3afd7348461c06cd81fd38ebfda8fbba904f8e3ea9b543f6545da1f2d5432955613f0fcf62d49705242a9af9e61e85dc0d651e40dfcf017b45575887", false},
+		{"pleaseletmein", "SodiumChloride", 1048576, 8, 1,
+			"2101cb9b6a511aaeaddbbe09cf70f881ec568d574a2ffd4dabe5ee9820adaa478e56fd8f4ba5d09ffa1c6d927c40f4c337304049e8a952fbcbf45c6fa77a41a4", true},
+	}
+	for _, tt := range tests {
+		if tt.slow && testing.Short() {
+			continue
+		}
+		got, err := scryptKey(tt.password, []byte(tt.salt), tt.n, tt.r, tt.p, 64)
+		if err != nil {
+			t.Fatalf("N=%d r=%d p=%d: %v", tt.n, tt.r, tt.p, err)
+		}
+		if h := hex.EncodeToString(got); h != tt.want {
+			t.Errorf("N=%d r=%d p=%d:\n got %s\nwant %s", tt.n, tt.r, tt.p, h, tt.want)
+		}
+	}
+}
+
+func TestScryptRejectsBadParams(t *testing.T) {
+	for _, p := range [][3]int{{0, 1, 1}, {1, 1, 1}, {15, 1, 1}, {16, 0, 1}, {16, 1, 0}} {
+		if _, err := scryptKey("pw", nil, p[0], p[1], p[2], 32); err != ErrKDFParams {
+			t.Errorf("N=%d r=%d p=%d: got %v, want ErrKDFParams", p[0], p[1], p[2], err)
+		}
+	}
+}
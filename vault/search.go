@@ -0,0 +1,153 @@
+// search.go
+// Substring, glob and fuzzy search over stored credentials
+// Zarak Khan
+
+package vault
+
+import (
+	"path"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// MatchMode selects how Query.Text is compared with entry fields.
+type MatchMode int
+
+const (
+	Substring MatchMode = iota // case-insensitive substring
+	Glob                       // shell-style pattern: * ? [abc]
+	Fuzzy                      // characters in order, gaps allowed
+)
+
+// Query describes a search. An empty Text matches every entry, and a
+// non-empty Tag restricts results to entries carrying that tag.
+type Query struct {
+	Text string
+	Mode MatchMode
+	Tag  string
+}
+
+// Result is one matching entry and its relevance score.
+type Result struct {
+	Entry
+	Score int
+}
+
+// Field weights: a hit on the site name counts for more than one buried
+// in the notes.
+const (
+	weightSite = 8
+	weightUser = 6
+	weightTag  = 4
+	weightNote = 1
+)
+
+// Search returns the entries matching q, best match first. Ties are
+// broken by site and then user.
+func (s *Store) Search(q Query) []Result {
+	text := strings.ToLower(q.Text)
+	var out []Result
+	for _, e := range s.List() {
+		if q.Tag != "" && !e.HasTag(q.Tag) {
+			continue
+		}
+		score := 1
+		if text != "" {
+			score = scoreEntry(e, text, q.Mode)
+		}
+		if score > 0 {
+			out = append(out, Result{Entry: e, Score: score})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		a, b := out[i], out[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if a.Site != b.Site {
+			return a.Site < b.Site
+		}
+		return a.User < b.User
+	})
+	return out
+}
+
+// scoreEntry returns the best weighted field score of e, or 0 for no
+// match. text is already lower-cased.
+func scoreEntry(e Entry, text string, mode MatchMode) int {
+	best := 0
+	try := func(field string, weight int) {
+		if sc := scoreField(strings.ToLower(field), text, mode) * weight; sc > best {
+			best = sc
+		}
+	}
+	try(e.Site, weightSite)
+	try(e.User, weightUser)
+	for _, t := range e.Tags {
+		try(t, weightTag)
+	}
+	try(e.Notes, weightNote)
+	return best
+}
+
+// scoreField scores one lower-cased field against text: higher is better
+// and 0 means no match.
+func scoreField(field, text string, mode MatchMode) int {
+	if field == "" {
+		return 0
+	}
+	switch mode {
+	case Glob:
+		if ok, _ := path.Match(text, field); ok {
+			return 10
+		}
+		return 0
+	case Fuzzy:
+		return fuzzyScore(field, text)
+	}
+
+	switch {
+	case field == text:
+		return 10
+	case strings.HasPrefix(field, text):
+		return 7
+	case strings.Contains(field, text):
+		return 4
+	}
+	return 0
+}
+
+// fuzzyScore matches the runes of text in order within field. Runs of
+// consecutive matches and a match at the start of field score higher;
+// every skipped rune costs a little.
+func fuzzyScore(field, text string) int {
+	score, run, gaps := 0, 0, 0
+	fi := 0
+	for _, want := range text {
+		found := false
+		for fi < len(field) {
+			r, size := utf8.DecodeRuneInString(field[fi:])
+			fi += size
+			if r == want {
+				found = true
+				break
+			}
+			run = 0
+			gaps++
+		}
+		if !found {
+			return 0
+		}
+		run++
+		score += 1 + run
+		if fi == utf8.RuneLen(want) {
+			score += 3 // matched the first rune of the field
+		}
+	}
+	score -= gaps / 2
+	if score < 1 {
+		score = 1
+	}
+	return score
+}
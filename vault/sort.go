@@ -0,0 +1,57 @@
+// sort.go
+// Deterministic ordering of entries
+// Zarak Khan
+
+package vault
+
+import (
+	"errors"
+	"sort"
+)
+
+// SortKey selects the order of a listing.
+type SortKey int
+
+const (
+	BySite     SortKey = iota // site, then user
+	ByUser                    // user, then site
+	ByModified                // most recently modified first
+)
+
+// ErrSortKey is returned by ParseSortKey for an unknown key.
+var ErrSortKey = errors.New("vault: unknown sort key")
+
+// ParseSortKey converts "site", "user" or "modified" to a SortKey.
+func ParseSortKey(s string) (SortKey, error) {
+	switch s {
+	case "site":
+		return BySite, nil
+	case "user":
+		return ByUser, nil
+	case "modified":
+		return ByModified, nil
+	}
+	return BySite, ErrSortKey
+}
+
+// Sort orders es by key. Ties always fall back to site and then user, so
+// the result does not depend on map iteration order.
+func (es EntrySlice) Sort(key SortKey) {
+	sort.SliceStable(es, func(i, j int) bool {
+		a, b := es[i], es[j]
+		switch key {
+		case ByUser:
+			if a.User != b.User {
+				return a.User < b.User
+			}
+		case ByModified:
+			if !a.Modified.Equal(b.Modified) {
+				return a.Modified.After(b.Modified)
+			}
+		}
+		if a.Site != b.Site {
+			return a.Site < b.Site
+		}
+		return a.User < b.User
+	})
+}
@@ -0,0 +1,413 @@
+// store.go
+// In-memory credential store shared by the password manager tools
+// Zarak Khan
+//
+// Package vault holds credentials keyed by website. Each site may have
+// several users, and a (site, user) pair is unique. Stores can be sealed
+// into an encrypted vault file (see file.go) or filled from the legacy
+// plaintext format (see legacy.go).
+//
+// A Store is safe for concurrent use. Every method locks it for its own
+// duration, and entries are returned as deep copies, so a caller can
+// never observe or cause a change through shared slices.
+
+package vault
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Errors returned by Store operations.
+var (
+	ErrDuplicate       = errors.New("vault: duplicate entry")
+	ErrSiteNotFound    = errors.New("vault: site not found")
+	ErrUserNotFound    = errors.New("vault: user not found")
+	ErrAmbiguousRemove = errors.New("vault: cannot remove a site with multiple users")
+	ErrAmbiguousUser   = errors.New("vault: site has multiple users; a user is required")
+	ErrNoVersion       = errors.New("vault: no such password version")
+)
+
+// MaxHistory is the number of previous passwords kept per entry.
+const MaxHistory = 10
+
+// now is the clock used for entry timestamps.
+var now = time.Now
+
+// PasswordChange is a password an entry used to have.
+type PasswordChange struct {
+	Password string
+	Replaced time.Time // when it stopped being the current password
+}
+
+// Field is a custom key/value pair on an entry, such as a security
+// question answer. Secret fields are masked like passwords.
+type Field struct {
+	Name, Value string
+	Secret      bool
+}
+
+// Meta is the descriptive information attached to an entry.
+type Meta struct {
+	URL    string
+	Notes  string
+	Tags   []string
+	Fields []Field
+	TOTP   string // otpauth:// URI of the two-factor secret (see package totp)
+}
+
+// HasTag reports whether the entry carries tag, ignoring case.
+func (m Meta) HasTag(tag string) bool {
+	for _, t := range m.Tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// Field returns the custom field called name.
+func (m Meta) Field(name string) (Field, bool) {
+	for _, f := range m.Fields {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return Field{}, false
+}
+
+// SetField adds the custom field f, replacing any field of the same name.
+func (m *Meta) SetField(f Field) {
+	for i := range m.Fields {
+		if m.Fields[i].Name == f.Name {
+			m.Fields[i] = f
+			return
+		}
+	}
+	m.Fields = append(m.Fields, f)
+}
+
+// DeleteField removes the custom field called name, if present.
+func (m *Meta) DeleteField(name string) {
+	for i := range m.Fields {
+		if m.Fields[i].Name == name {
+			m.Fields = append(m.Fields[:i:i], m.Fields[i+1:]...)
+			return
+		}
+	}
+}
+
+// clone returns a copy of m that shares no slices with it.
+func (m Meta) clone() Meta {
+	m.Tags = slices.Clone(m.Tags)
+	m.Fields = slices.Clone(m.Fields)
+	return m
+}
+
+// Entry represents one credential record.
+type Entry struct {
+	Site, User, Password string
+	Meta
+	Created  time.Time
+	Modified time.Time        // last change of any kind
+	History  []PasswordChange // up to MaxHistory previous passwords, oldest first
+}
+
+// clone returns a copy of e that shares no slices with it.
+func (e Entry) clone() Entry {
+	e.Meta = e.Meta.clone()
+	e.History = slices.Clone(e.History)
+	return e
+}
+
+// PasswordChanged returns when e's current password was set: when the
+// previous one was replaced, or when the entry was created. Unlike
+// Modified it ignores renames and metadata edits.
+func (e Entry) PasswordChanged() time.Time {
+	if n := len(e.History); n > 0 {
+		return e.History[n-1].Replaced
+	}
+	return e.Created
+}
+
+// EntrySlice is a helper alias for slices of Entry.
+type EntrySlice []Entry
+
+// Store maps a website to its stored credentials. mu guards both fields;
+// unexported helpers expect the caller to hold it.
+type Store struct {
+	mu    sync.RWMutex
+	sites map[string]EntrySlice
+	kdf   KDFParams // cost read from the vault file; zero for a new store
+}
+
+// New returns an empty Store.
+func New() *Store {
+	return &Store{sites: make(map[string]EntrySlice)}
+}
+
+// Len returns the number of entries in the store.
+func (s *Store) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	n := 0
+	for _, slice := range s.sites {
+		n += len(slice)
+	}
+	return n
+}
+
+// Add inserts a credential. It returns ErrDuplicate if (site, user) is
+// already present.
+func (s *Store) Add(site, user, pass string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t := now()
+	return s.put(Entry{Site: site, User: user, Password: pass, Created: t, Modified: t})
+}
+
+// Restore inserts a complete entry, such as one returned by Get, keeping
+// its timestamps, metadata and history. It returns ErrDuplicate if (site,
+// user) is already present.
+func (s *Store) Restore(e Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.put(e.clone())
+}
+
+// put inserts e as-is, keeping its timestamps and history.
+func (s *Store) put(e Entry) error {
+	if s.index(e.Site, e.User) != -1 {
+		return ErrDuplicate
+	}
+	s.sites[e.Site] = append(s.sites[e.Site], e)
+	return nil
+}
+
+// Update changes the user name and/or password of an existing entry in
+// place. An empty newUser or newPass leaves that field unchanged. The
+// replaced password is kept in the entry's history.
+func (s *Store) Update(site, user, newUser, newPass string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.sites[site]; !ok {
+		return ErrSiteNotFound
+	}
+	idx := s.index(site, user)
+	if idx == -1 {
+		return ErrUserNotFound
+	}
+	if newUser != "" && newUser != user && s.index(site, newUser) != -1 {
+		return ErrDuplicate
+	}
+
+	e := &s.sites[site][idx]
+	t := now()
+	if newUser != "" && newUser != user {
+		e.User = newUser
+		e.Modified = t
+	}
+	if newPass != "" && newPass != e.Password {
+		e.setPassword(newPass, t)
+	}
+	return nil
+}
+
+// SetMeta replaces the descriptive information of an existing entry.
+func (s *Store) SetMeta(site, user string, m Meta) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.sites[site]; !ok {
+		return ErrSiteNotFound
+	}
+	idx := s.index(site, user)
+	if idx == -1 {
+		return ErrUserNotFound
+	}
+	e := &s.sites[site][idx]
+	e.Meta = m.clone()
+	e.Modified = now()
+	return nil
+}
+
+// Rollback restores the password an entry had n changes ago (1 is the
+// previous password). The current password moves into the history, so a
+// rollback can itself be rolled back.
+func (s *Store) Rollback(site, user string, n int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.sites[site]; !ok {
+		return ErrSiteNotFound
+	}
+	idx := s.index(site, user)
+	if idx == -1 {
+		return ErrUserNotFound
+	}
+	e := &s.sites[site][idx]
+	if n < 1 || n > len(e.History) {
+		return ErrNoVersion
+	}
+	i := len(e.History) - n
+	old := e.History[i].Password
+	e.History = append(e.History[:i:i], e.History[i+1:]...)
+	e.setPassword(old, now())
+	return nil
+}
+
+// setPassword replaces the password, pushing the current one onto the
+// bounded history.
+func (e *Entry) setPassword(pass string, t time.Time) {
+	e.History = append(e.History, PasswordChange{Password: e.Password, Replaced: t})
+	if len(e.History) > MaxHistory {
+		e.History = append([]PasswordChange(nil), e.History[len(e.History)-MaxHistory:]...)
+	}
+	e.Password = pass
+	e.Modified = t
+}
+
+// Remove deletes one user from site. With an empty user the whole site is
+// removed, which is only allowed when it has a single user; otherwise
+// ErrAmbiguousRemove is returned.
+func (s *Store) Remove(site, user string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	slice, ok := s.sites[site]
+	if !ok {
+		return ErrSiteNotFound
+	}
+	if user == "" {
+		if len(slice) > 1 {
+			return ErrAmbiguousRemove
+		}
+		delete(s.sites, site)
+		return nil
+	}
+
+	idx := s.index(site, user)
+	if idx == -1 {
+		return ErrUserNotFound
+	}
+	slice = append(slice[:idx:idx], slice[idx+1:]...)
+	if len(slice) == 0 {
+		delete(s.sites, site)
+	} else {
+		s.sites[site] = slice
+	}
+	return nil
+}
+
+// Get returns the entry for (site, user). With an empty user the site's
+// only entry is returned, or ErrAmbiguousUser if it has several.
+func (s *Store) Get(site, user string) (Entry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	slice, ok := s.sites[site]
+	if !ok {
+		return Entry{}, ErrSiteNotFound
+	}
+	if user == "" {
+		if len(slice) > 1 {
+			return Entry{}, ErrAmbiguousUser
+		}
+		return slice[0].clone(), nil
+	}
+	idx := s.index(site, user)
+	if idx == -1 {
+		return Entry{}, ErrUserNotFound
+	}
+	return slice[idx].clone(), nil
+}
+
+// Site returns a copy of the entries stored for site, sorted by user.
+func (s *Store) Site(site string) (EntrySlice, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	slice, ok := s.sites[site]
+	if !ok {
+		return nil, ErrSiteNotFound
+	}
+	out := make(EntrySlice, len(slice))
+	for i, e := range slice {
+		out[i] = e.clone()
+	}
+	out.Sort(BySite)
+	return out, nil
+}
+
+// List returns a copy of every entry, sorted by site and then user. The
+// copy is taken under a single lock, so it is a consistent snapshot even
+// while other goroutines change the store.
+func (s *Store) List() EntrySlice {
+	s.mu.RLock()
+	var out EntrySlice
+	for _, slice := range s.sites {
+		for _, e := range slice {
+			out = append(out, e.clone())
+		}
+	}
+	s.mu.RUnlock()
+	out.Sort(BySite)
+	return out
+}
+
+// Snapshot returns an independent copy of the whole store, for callers
+// that read it in several steps and need them to agree.
+func (s *Store) Snapshot() *Store {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	c := &Store{sites: make(map[string]EntrySlice, len(s.sites)), kdf: s.kdf}
+	for site, slice := range s.sites {
+		cs := make(EntrySlice, len(slice))
+		for i, e := range slice {
+			cs[i] = e.clone()
+		}
+		c.sites[site] = cs
+	}
+	return c
+}
+
+// Wipe removes every entry, clearing their secrets first so no reference
+// to the decrypted data outlives the call. Go strings cannot be overwritten
+// in place; the memory is released to the garbage collector instead.
+func (s *Store) Wipe() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for site, slice := range s.sites {
+		for i := range slice {
+			slice[i] = Entry{}
+		}
+		delete(s.sites, site)
+	}
+}
+
+// Replace makes s hold the entries and scrypt cost of other, for example
+// after the vault file was changed by another process. The old entries
+// are cleared as by Wipe. other must not be used afterwards.
+func (s *Store) Replace(other *Store) {
+	other.mu.Lock()
+	sites, kdf := other.sites, other.kdf
+	other.sites = make(map[string]EntrySlice)
+	other.mu.Unlock()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, slice := range s.sites {
+		for i := range slice {
+			slice[i] = Entry{}
+		}
+	}
+	s.sites, s.kdf = sites, kdf
+}
+
+// index returns the position of user within site, or -1.
+func (s *Store) index(site, user string) int {
+	for i, e := range s.sites[site] {
+		if e.User == user {
+			return i
+		}
+	}
+	return -1
+}
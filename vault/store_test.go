@@ -0,0 +1,153 @@
+// store_test.go
+// Concurrent use of a Store; run with go test -race
+// Zarak Khan
+
+package vault
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// TestConcurrentWriters runs adders, updaters, removers and importers
+// against shared sites while readers list, snapshot and search, then
+// checks that no write was lost.
+func TestConcurrentWriters(t *testing.T) {
+	const (
+		workers = 8
+		perWork = 100
+		sites   = 10
+	)
+	s := New()
+	var wg sync.WaitGroup
+
+	for g := range workers {
+		wg.Add(2)
+		// Each writer owns its user names, so every step must succeed.
+		go func() {
+			defer wg.Done()
+			for i := range perWork {
+				site, user := fmt.Sprint("site", i%sites), fmt.Sprintf("u%d-%d", g, i)
+				if err := s.Add(site, user, "first"); err != nil {
+					t.Errorf("Add(%s, %s): %v", site, user, err)
+					return
+				}
+				if err := s.Update(site, user, "", "second"); err != nil {
+					t.Errorf("Update(%s, %s): %v", site, user, err)
+					return
+				}
+				if i%2 == 0 {
+					if err := s.Remove(site, user); err != nil {
+						t.Errorf("Remove(%s, %s): %v", site, user, err)
+						return
+					}
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			var rep ImportReport
+			for i := range perWork {
+				e := Entry{Site: fmt.Sprint("site", i%sites), User: fmt.Sprintf("imp%d-%d", g, i), Password: "imported"}
+				s.importEntry(e, LineIssue{Line: i + 1}, DupSkip, &rep)
+			}
+			if rep.Added != perWork || len(rep.Duplicates) != 0 {
+				t.Errorf("importer %d: added %d with %d duplicates", g, rep.Added, len(rep.Duplicates))
+			}
+		}()
+	}
+	for range workers / 2 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 20 {
+				for _, e := range s.List() {
+					// Changing a returned copy must not reach the store.
+					e.Tags = append(e.Tags, "changed")
+					e.History = append(e.History, PasswordChange{Password: "changed"})
+				}
+				snap := s.Snapshot()
+				if n := len(snap.List()); n != snap.Len() {
+					t.Errorf("snapshot lists %d entries but has %d", n, snap.Len())
+					return
+				}
+				s.Search(Query{Text: "site1"})
+			}
+		}()
+	}
+	wg.Wait()
+
+	want := workers*perWork/2 + workers*perWork
+	if got := s.Len(); got != want {
+		t.Fatalf("Len = %d, want %d", got, want)
+	}
+	seen := make(map[string]bool)
+	for _, e := range s.List() {
+		key := e.Site + " " + e.User
+		if seen[key] {
+			t.Errorf("duplicate entry %s", key)
+		}
+		seen[key] = true
+		if len(e.Tags) != 0 {
+			t.Errorf("%s: tags %v leaked in from a reader's copy", key, e.Tags)
+		}
+		switch e.Password {
+		case "second":
+			if len(e.History) != 1 || e.History[0].Password != "first" {
+				t.Errorf("%s: history %v, want one change from first", key, e.History)
+			}
+		case "imported":
+			if len(e.History) != 0 {
+				t.Errorf("%s: history %v, want none", key, e.History)
+			}
+		default:
+			t.Errorf("%s: unexpected password %q", key, e.Password)
+		}
+	}
+}
+
+// TestConcurrentSameEntry has several goroutines race to add, rename and
+// remove the same entry; whatever the interleaving, the store must stay
+// consistent.
+func TestConcurrentSameEntry(t *testing.T) {
+	s := New()
+	var wg sync.WaitGroup
+	for g := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 200 {
+				s.Add("shared", "alice", fmt.Sprint("pw", g))
+				s.Update("shared", "alice", "", fmt.Sprint("new", g))
+				s.Get("shared", "alice")
+				s.Remove("shared", "alice")
+			}
+		}()
+	}
+	wg.Wait()
+	if n := s.Len(); n > 1 {
+		t.Fatalf("Len = %d after racing on one entry", n)
+	}
+}
+
+// TestSnapshotIsIndependent checks that a snapshot and the store no
+// longer share entries.
+func TestSnapshotIsIndependent(t *testing.T) {
+	s := New()
+	s.Add("a.com", "bob", "one")
+	snap := s.Snapshot()
+	s.Update("a.com", "bob", "", "two")
+	s.Add("b.com", "carol", "three")
+
+	e, err := snap.Get("a.com", "bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Password != "one" || len(e.History) != 0 {
+		t.Errorf("snapshot entry changed: %+v", e)
+	}
+	if snap.Len() != 1 {
+		t.Errorf("snapshot Len = %d, want 1", snap.Len())
+	}
+}
@@ -0,0 +1,300 @@
+// vaults.go
+// Named vaults: one file and master password each, in a shared directory
+// Zarak Khan
+//
+// A named vault "work" is stored as work.pmv in $PM_VAULT_DIR (default
+// pm/vaults under the user's config directory). It is selected with the
+// -use flag or the shell's "use" command, listed by "vaults", and entries
+// are moved between vaults with "move".
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ZarakL/Go-Projects/vault"
+)
+
+// vaultExt is the file extension of named vaults.
+const vaultExt = ".pmv"
+
+var (
+	badVaultNameErr  = usageError("Vault names may only contain letters, digits, '.', '-' and '_', and may not start with '.'.")
+	sameVaultErr     = usageError("The entries are already in that vault.")
+	useAndVaultErr   = usageError("Give either -vault or -use, not both.")
+	noVaultDirErr    = errors.New("**Error: Cannot find a directory for named vaults; set PM_VAULT_DIR.")
+	switchAbortedErr = errors.New("**Error: Unsaved changes could not be saved; vault not switched.")
+)
+
+// vaultDir returns the directory that holds the named vaults.
+func vaultDir() (string, error) {
+	if d := os.Getenv("PM_VAULT_DIR"); d != "" {
+		return d, nil
+	}
+	d, err := os.UserConfigDir()
+	if err != nil {
+		return "", noVaultDirErr
+	}
+	return filepath.Join(d, "pm", "vaults"), nil
+}
+
+// validVaultName reports whether name can be used as a file name in the
+// vault directory.
+func validVaultName(name string) bool {
+	if name == "" || name[0] == '.' {
+		return false
+	}
+	for _, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-', r == '_':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// namedVaultPath returns the file for the named vault, creating the vault
+// directory if needed so a new vault can be saved there.
+func namedVaultPath(name string) (string, error) {
+	if !validVaultName(name) {
+		return "", badVaultNameErr
+	}
+	dir, err := vaultDir()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name+vaultExt), nil
+}
+
+// listVaults returns the names of the vaults in the vault directory,
+// sorted.
+func listVaults() ([]string, error) {
+	dir, err := vaultDir()
+	if err != nil {
+		return nil, err
+	}
+	des, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, de := range des {
+		if name, ok := strings.CutSuffix(de.Name(), vaultExt); ok && de.Type().IsRegular() && validVaultName(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// printVaults lists the named vaults on w, marking the one in use.
+func printVaults(w io.Writer) error {
+	names, err := listVaults()
+	if err != nil {
+		return err
+	}
+	if len(names) == 0 {
+		fmt.Fprintln(w, "No named vaults yet.")
+		return nil
+	}
+	for _, name := range names {
+		mark := " "
+		if path, err := namedVaultPath(name); err == nil && sameFile(path, vaultPath) {
+			mark = "*"
+		}
+		fmt.Fprintf(w, "%s %s\n", mark, name)
+	}
+	return nil
+}
+
+// sameFile reports whether a and b name the same vault file.
+func sameFile(a, b string) bool {
+	if a == "" || b == "" {
+		return false
+	}
+	aa, err1 := filepath.Abs(a)
+	bb, err2 := filepath.Abs(b)
+	return err1 == nil && err2 == nil && aa == bb
+}
+
+// openNamedVault unlocks the named vault, prompting on w for its master
+// password. A vault that does not exist yet is returned empty, with a
+// newly chosen password; it is created when first saved.
+func openNamedVault(name string, w io.Writer) (s *vault.Store, path, password string, err error) {
+	path, err = namedVaultPath(name)
+	if err != nil {
+		return nil, "", "", err
+	}
+	prompt := fmt.Sprintf("Master password for vault %q: ", name)
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		fmt.Fprintf(w, "Vault %q does not exist yet; it will be created.\n", name)
+		password, err = readNewPassword(w, prompt)
+		return vault.New(), path, password, err
+	}
+	if password, err = readPassword(w, prompt); err != nil {
+		return nil, "", "", err
+	}
+	s, err = vault.Open(path, password)
+	return s, path, password, err
+}
+
+// moveEntries moves site's entries, or only user's if given, from store
+// into dst and saves dst to path before removing them from store. If any
+// of them is already in dst, nothing is moved. It returns the number of
+// entries moved; store is left for the caller to save.
+func moveEntries(site, user string, dst *vault.Store, path, password string) (int, error) {
+	var entries vault.EntrySlice
+	if user != "" {
+		e, err := store.Get(site, user)
+		if err != nil {
+			return 0, err
+		}
+		entries = vault.EntrySlice{e}
+	} else {
+		es, err := store.Site(site)
+		if err != nil {
+			return 0, err
+		}
+		entries = es
+	}
+	for _, e := range entries {
+		if _, err := dst.Get(e.Site, e.User); err == nil {
+			return 0, fmt.Errorf("**Error: %s already has user %s in the target vault. Nothing was moved.", e.Site, e.User)
+		}
+	}
+	for _, e := range entries {
+		if err := dst.Restore(e); err != nil {
+			return 0, err
+		}
+	}
+	// Save the copies first: if removing the originals fails, the entries
+	// are in both vaults rather than in neither.
+	if err := dst.Save(path, password); err != nil {
+		return 0, err
+	}
+	for _, e := range entries {
+		if err := store.Remove(e.Site, e.User); err != nil {
+			return 0, err
+		}
+	}
+	dirty = true
+	return len(entries), nil
+}
+
+// useVault handles the shell's "use" command: save the current vault if
+// it has changes, then unlock the named vault in its place. Without a
+// name it lists the vaults instead.
+func useVault(reader *bufio.Reader, name string) error {
+	if name == "" {
+		return printVaults(os.Stdout)
+	}
+	path, err := namedVaultPath(name)
+	if err != nil {
+		return err
+	}
+	if sameFile(path, vaultPath) {
+		fmt.Printf("Already using vault %q.\n", name)
+		return nil
+	}
+	if dirty && !save(reader) {
+		return switchAbortedErr
+	}
+	s, path, pw, err := openNamedVault(name, os.Stdout)
+	if err != nil {
+		return err
+	}
+	// Nothing from the previous vault may outlive the switch.
+	clip.Flush()
+	store.Wipe()
+	store, vaultPath, masterPassword, dirty = s, path, pw, false
+	fmt.Printf("Using vault %q.\n", name)
+	return nil
+}
+
+// moveToVault handles the shell's "move" command.
+func moveToVault(reader *bufio.Reader) error {
+	fmt.Print("Enter the site and username (separated by spaces, username optional): ")
+	line, _ := reader.ReadString('\n')
+	parts := append(strings.Fields(line), "", "")
+	if parts[0] == "" {
+		return nil
+	}
+	fmt.Print("Enter the name of the vault to move to: ")
+	name, _ := reader.ReadString('\n')
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil
+	}
+	n, err := moveTo(name, parts[0], parts[1], os.Stdout)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Moved %d entries to vault %q. Save to remove them from this vault's file.\n", n, name)
+	return nil
+}
+
+// moveTo unlocks the named vault and moves entries into it (see
+// moveEntries).
+func moveTo(name, site, user string, w io.Writer) (int, error) {
+	path, err := namedVaultPath(name)
+	if err != nil {
+		return 0, err
+	}
+	if sameFile(path, vaultPath) {
+		return 0, sameVaultErr
+	}
+	dst, path, pw, err := openNamedVault(name, w)
+	if err != nil {
+		return 0, err
+	}
+	defer dst.Wipe()
+	return moveEntries(site, user, dst, path, pw)
+}
+
+// cmdVaults lists the named vaults.
+func cmdVaults(args []string) error {
+	if len(args) != 0 {
+		return usageError("usage: vaults")
+	}
+	return printVaults(os.Stdout)
+}
+
+// cmdMove moves a site, or one of its users, to another named vault. The
+// master password of the current vault is read first, then that of the
+// target.
+func cmdMove(args []string) error {
+	const use = "usage: move -to name site [user]"
+	fs := flag.NewFlagSet("move", flag.ContinueOnError)
+	to := fs.String("to", "", "`name` of the vault to move the entries to")
+	if err := fs.Parse(args); err != nil || *to == "" || fs.NArg() < 1 || fs.NArg() > 2 {
+		return usageError(use)
+	}
+	site, user := fs.Arg(0), fs.Arg(1)
+	if err := openVault(false); err != nil {
+		return err
+	}
+	n, err := moveTo(*to, site, user, os.Stderr)
+	if err != nil {
+		return err
+	}
+	if err := commit(); err != nil {
+		return err
+	}
+	fmt.Printf("Moved %d entries to vault %q.\n", n, *to)
+	return nil
+}